@@ -1,20 +1,25 @@
 package controllers
 
 import (
-	"fmt"
+	"bufio"
 	"time"
 
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
 	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 type AdminController struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Agenda *services.AgendaStream
 }
 
-func NewAdminController(db *gorm.DB) *AdminController {
-	return &AdminController{DB: db}
+func NewAdminController(db *gorm.DB, agenda *services.AgendaStream) *AdminController {
+	return &AdminController{DB: db, Agenda: agenda}
 }
 
 // GetDashboardStats returns actionable summary metrics
@@ -22,13 +27,14 @@ func (ac *AdminController) GetDashboardStats(c *fiber.Ctx) error {
 	var totalRevenueToday float64
 	var activeSessionsToday int64
 	var pendingActions int64
-	agenda := []fiber.Map{} // Initialize as empty slice to avoid null JSON
 
-	today := time.Now().Truncate(24 * time.Hour)
+	// Today is the studio's calendar day, not the server's or UTC's
+	todayStart := utils.StudioToday()
+	today := todayStart.Format("2006-01-02")
 
 	// 1. Revenue Today (Paid reservations updated today)
 	ac.DB.Model(&models.Reservation{}).
-		Where("status = ? AND updated_at >= ?", "paid", today).
+		Where("status = ? AND updated_at >= ?", "paid", todayStart).
 		Select("COALESCE(SUM(total_amount), 0)").
 		Scan(&totalRevenueToday)
 
@@ -45,56 +51,9 @@ func (ac *AdminController) GetDashboardStats(c *fiber.Ctx) error {
 		Count(&pendingActions)
 
 	// 4. Agenda (All schedules today)
-	var schedules []models.Schedule
-	ac.DB.Preload("Court").
-		Where("date = ?", today).
-		Order("start_time ASC").
-		Find(&schedules)
-
-	// Construct Agenda: For each schedule, find if there are bookings
-	for _, s := range schedules {
-		var bookings []models.Reservation
-		ac.DB.Preload("User").Where("schedule_id = ? AND status IN ('paid', 'confirmed', 'pending')", s.ID).Find(&bookings)
-
-		status := "Available"
-		customerName := ""
-		statusColor := "green" // green, yellow, red, grey
-
-		// Capacity Logic for Agenda display
-		if !s.IsAvailable {
-			status = "Full / Closed"
-			statusColor = "red"
-		}
-
-		if len(bookings) > 0 {
-			// Simplified: Show first booker or "X Bookings"
-			if len(bookings) == 1 {
-				customerName = bookings[0].User.Name
-				if bookings[0].Status == "pending" {
-					status = "Pending"
-					statusColor = "yellow"
-				} else {
-					status = "Booked"
-					statusColor = "blue"
-				}
-			} else {
-				status = fmt.Sprintf("%d Bookings", len(bookings))
-				statusColor = "blue"
-			}
-		} else if !s.IsAvailable {
-			statusColor = "grey" // Maintenance or just manually closed
-		}
-
-		agenda = append(agenda, fiber.Map{
-			"schedule_id":  s.ID,
-			"court_name":   s.Court.Name,
-			"time":         s.StartTime + " - " + s.EndTime,
-			"status":       status,
-			"status_color": statusColor,
-			"customer":     customerName,
-			"bookings":     bookings, // Send full list if needed
-			"is_available": s.IsAvailable,
-		})
+	agenda, err := services.BuildAgenda(ac.DB, ac.DB.Where("schedules.date = ?", today))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load agenda"})
 	}
 
 	return c.JSON(fiber.Map{
@@ -105,6 +64,54 @@ func (ac *AdminController) GetDashboardStats(c *fiber.Ctx) error {
 	})
 }
 
+// StreamAgenda pushes the agenda of a date (default today) as Server-Sent
+// Events: a "snapshot" with every row, then a "delta" per new booking,
+// payment, cancellation or check-in carrying the updated row. Reconnects
+// with Last-Event-ID resume from the missed deltas when still buffered.
+// GET /api/admin/agenda/stream?date=YYYY-MM-DD
+func (ac *AdminController) StreamAgenda(c *fiber.Ctx) error {
+	date := c.Query("date", utils.StudioNow().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid date format (YYYY-MM-DD)"})
+	}
+
+	sub, missed, resumed, cursor := ac.Agenda.Subscribe(services.AgendaFilter(date), lastEventID(c))
+
+	var snapshot []services.AgendaItem
+	if !resumed {
+		var err error
+		snapshot, err = services.BuildAgenda(ac.DB, ac.DB.Where("schedules.date = ?", date))
+		if err != nil {
+			ac.Agenda.Unsubscribe(sub)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load agenda"})
+		}
+	}
+
+	setSSEHeaders(c)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer ac.Agenda.Unsubscribe(sub)
+
+		if writeSSERetry(w) != nil {
+			return
+		}
+
+		if resumed {
+			for _, m := range missed {
+				if writeSSE(w, m.ID, "delta", m.Data) != nil {
+					return
+				}
+			}
+		} else if writeSSE(w, cursor, "snapshot", fiber.Map{"date": date, "agenda": snapshot}) != nil {
+			return
+		}
+
+		streamSSE(w, sub.Messages, "delta")
+	})
+
+	return nil
+}
+
 type CreateManualReservationInput struct {
 	ScheduleID   string `json:"schedule_id" validate:"required,uuid"`
 	CustomerName string `json:"customer_name"` // For manual, we might create a dummy user or just notes?
@@ -134,7 +141,7 @@ func (ac *AdminController) CreateManualReservation(c *fiber.Ctx) error {
 	}
 
 	// 2. Lock Schedule & Check Capacity (Reusing logic logic)
-	tx := ac.DB.Begin()
+	tx := domain.WithActor(ac.DB.Begin(), actorFromContext(c, domain.SourceManualBooking))
 
 	var schedule models.Schedule
 	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&schedule, "id = ?", input.ScheduleID).Error; err != nil {
@@ -147,23 +154,25 @@ func (ac *AdminController) CreateManualReservation(c *fiber.Ctx) error {
 	var court models.Court
 	tx.First(&court, "id = ?", schedule.CourtID)
 
-	var currentBookings int64
-	tx.Model(&models.Reservation{}).
-		Where("schedule_id = ? AND status IN ('pending', 'paid', 'confirmed')", schedule.ID).
-		Count(&currentBookings)
+	currentBookings, err := domain.BookedSeats(tx, schedule.ID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check capacity"})
+	}
 
-	if int(currentBookings) >= court.Capacity {
+	if currentBookings >= schedule.SeatCapacity(court) {
 		tx.Rollback()
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Fully booked"})
 	}
 
-	// Create Reservation (Paid directly)
+	// Create Reservation; it becomes paid through the cash payment below
 	reservation := models.Reservation{
 		UserID:      user.ID,
 		CourtID:     schedule.CourtID,
 		ScheduleID:  schedule.ID,
-		Status:      "paid", // Admin booking is considered paid/confirmed
-		TotalAmount: court.PricePerSlot,
+		Seats:       1,
+		Status:      domain.ReservationPending,
+		TotalAmount: schedule.SlotPrice(court),
 		Notes:       "Manual Booking: " + input.Notes,
 	}
 
@@ -172,18 +181,37 @@ func (ac *AdminController) CreateManualReservation(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create"})
 	}
 
+	if err := events.Publish(tx, events.ReservationCreated, reservation.ID, events.NewReservationPayload(&reservation, "")); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create"})
+	}
+
+	if err := domain.RecordReservationEvent(tx, reservation.ID, domain.TimelineCreated, "", reservation.Status, events.NewReservationPayload(&reservation, "")); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create"})
+	}
+
 	// Create Dummy Payment record for consistency (Cash)
 	payment := models.Payment{
 		ReservationID:   reservation.ID,
 		MidtransOrderID: "MANUAL-" + reservation.ID,
 		Amount:          reservation.TotalAmount,
-		Status:          "success",
+		Status:          domain.PaymentPending,
 		PaymentMethod:   "manual_cash",
 	}
-	tx.Create(&payment)
+	if err := tx.Create(&payment).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payment"})
+	}
+
+	// Admin booking is considered paid
+	if err := domain.ApplyPaymentStatus(tx, &payment, domain.PaymentSuccess); err != nil {
+		tx.Rollback()
+		return transitionErrorResponse(c, err, "Failed to record payment")
+	}
 
 	// Update schedule if full
-	if int(currentBookings)+1 >= court.Capacity {
+	if currentBookings+1 >= schedule.SeatCapacity(court) {
 		tx.Model(&schedule).Update("is_available", false)
 	}
 
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+type APIKeyController struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyController(db *gorm.DB) *APIKeyController {
+	return &APIKeyController{DB: db}
+}
+
+type CreateAPIKeyInput struct {
+	Name               string     `json:"name" validate:"required,min=3"`
+	Scopes             []string   `json:"scopes" validate:"required,min=1"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute" validate:"omitempty,min=1,max=10000"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+}
+
+func apiKeyResponse(key *models.APIKey) fiber.Map {
+	return fiber.Map{
+		"id":                    key.ID,
+		"name":                  key.Name,
+		"prefix":                key.Prefix,
+		"scopes":                key.ScopeList(),
+		"rate_limit_per_minute": key.RateLimitPerMinute,
+		"user_id":               key.UserID,
+		"created_by_id":         key.CreatedByID,
+		"last_used_at":          key.LastUsedAt,
+		"expires_at":            key.ExpiresAt,
+		"revoked_at":            key.RevokedAt,
+		"created_at":            key.CreatedAt,
+	}
+}
+
+// GetAPIKeys lists all keys without their secret
+func (kc *APIKeyController) GetAPIKeys(c *fiber.Ctx) error {
+	var keys []models.APIKey
+	if err := kc.DB.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch API keys"})
+	}
+
+	result := []fiber.Map{}
+	for i := range keys {
+		result = append(result, apiKeyResponse(&keys[i]))
+	}
+
+	return c.JSON(fiber.Map{"data": result})
+}
+
+// CreateAPIKey issues a new key. The plaintext key is only returned in this response.
+func (kc *APIKeyController) CreateAPIKey(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(string)
+
+	var input CreateAPIKeyInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	for _, scope := range input.Scopes {
+		if !isKnownScope(scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":          "Unknown scope: " + scope,
+				"allowed_scopes": models.APIKeyScopes,
+			})
+		}
+	}
+
+	if input.RateLimitPerMinute == 0 {
+		input.RateLimitPerMinute = 60
+	}
+
+	plaintext, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate API key"})
+	}
+
+	key := models.APIKey{
+		Name:               input.Name,
+		Prefix:             prefix,
+		KeyHash:            utils.HashAPIKey(plaintext),
+		Scopes:             strings.Join(input.Scopes, ","),
+		RateLimitPerMinute: input.RateLimitPerMinute,
+		UserID:             adminID, // keys act as the issuing admin, never as a customer
+		CreatedByID:        adminID,
+		ExpiresAt:          input.ExpiresAt,
+	}
+
+	if err := kc.DB.Create(&key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create API key"})
+	}
+
+	services.RecordAudit(kc.DB, services.AuditEntry{
+		UserID:    adminID,
+		Action:    "api_key_created",
+		TableName: "api_keys",
+		RecordID:  key.ID,
+		NewData:   apiKeyResponse(&key),
+		IPAddress: c.IP(),
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "API key created. Store it now, it will not be shown again.",
+		"key":     plaintext,
+		"data":    apiKeyResponse(&key),
+	})
+}
+
+// RevokeAPIKey disables a key immediately
+func (kc *APIKeyController) RevokeAPIKey(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(string)
+	id := c.Params("id")
+
+	var key models.APIKey
+	if err := kc.DB.First(&key, "id = ?", id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "API key not found"})
+	}
+
+	if key.RevokedAt != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "API key already revoked"})
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := kc.DB.Save(&key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke API key"})
+	}
+
+	services.RecordAudit(kc.DB, services.AuditEntry{
+		UserID:    adminID,
+		Action:    "api_key_revoked",
+		TableName: "api_keys",
+		RecordID:  key.ID,
+		IPAddress: c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"message": "API key revoked"})
+}
+
+func isKnownScope(scope string) bool {
+	for _, s := range models.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
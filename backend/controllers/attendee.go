@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const attendeeClaimTTL = 7 * 24 * time.Hour
+
+type AttendeeController struct {
+	DB     *gorm.DB
+	Mailer services.Mailer
+}
+
+func NewAttendeeController(db *gorm.DB, mailer services.Mailer) *AttendeeController {
+	return &AttendeeController{DB: db, Mailer: mailer}
+}
+
+type AddAttendeeInput struct {
+	Name  string  `json:"name" validate:"required,min=2"`
+	Email string  `json:"email" validate:"required,email"`
+	Phone *string `json:"phone" validate:"omitempty,min=6,max=20"`
+}
+
+type ClaimAttendeeInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type UpdateWaiverInput struct {
+	Status string `json:"status" validate:"required,oneof=pending signed"`
+}
+
+// loadOwnReservation returns the reservation if it belongs to the caller
+func (ac *AttendeeController) loadOwnReservation(c *fiber.Ctx, db *gorm.DB) (*models.Reservation, error) {
+	var reservation models.Reservation
+	if err := db.First(&reservation, "id = ?", c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
+	}
+	if reservation.UserID != c.Locals("user_id").(string) {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized"})
+	}
+	return &reservation, nil
+}
+
+// GetAttendees lists the attendees of the caller's reservation
+// GET /api/reservations/:id/attendees
+func (ac *AttendeeController) GetAttendees(c *fiber.Ctx) error {
+	reservation, errResp := ac.loadOwnReservation(c, ac.DB)
+	if reservation == nil {
+		return errResp
+	}
+
+	var attendees []models.ReservationAttendee
+	if err := ac.DB.Where("reservation_id = ?", reservation.ID).Order("created_at ASC").Find(&attendees).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch attendees"})
+	}
+
+	return c.JSON(fiber.Map{"data": attendees, "seats": reservation.Seats})
+}
+
+// AddAttendee names the person for one seat and emails them a claim link
+// POST /api/reservations/:id/attendees
+func (ac *AttendeeController) AddAttendee(c *fiber.Ctx) error {
+	var input AddAttendeeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	tx := ac.DB.Begin()
+
+	// Lock the reservation so concurrent requests cannot exceed the seat count
+	reservation, errResp := ac.loadOwnReservation(c, tx.Clauses(clause.Locking{Strength: "UPDATE"}))
+	if reservation == nil {
+		tx.Rollback()
+		return errResp
+	}
+
+	if !domain.HoldsSeat(reservation.Status) {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reservation is no longer active"})
+	}
+
+	var count int64
+	if err := tx.Model(&models.ReservationAttendee{}).Where("reservation_id = ?", reservation.ID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add attendee"})
+	}
+	if int(count) >= reservation.Seats {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Every seat already has an attendee"})
+	}
+
+	attendee := models.ReservationAttendee{
+		ReservationID: reservation.ID,
+		Name:          input.Name,
+		Email:         input.Email,
+		Phone:         input.Phone,
+		WaiverStatus:  models.WaiverPending,
+	}
+	token, err := issueClaimToken(&attendee)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create claim link"})
+	}
+
+	if err := tx.Create(&attendee).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add attendee"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add attendee"})
+	}
+
+	ac.sendClaimEmail(&attendee, reservation, token)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"data": attendee})
+}
+
+// RemoveAttendee frees a seat that has not been claimed yet
+// DELETE /api/reservations/:id/attendees/:attendeeId
+func (ac *AttendeeController) RemoveAttendee(c *fiber.Ctx) error {
+	reservation, errResp := ac.loadOwnReservation(c, ac.DB)
+	if reservation == nil {
+		return errResp
+	}
+
+	var attendee models.ReservationAttendee
+	if err := ac.DB.First(&attendee, "id = ? AND reservation_id = ?", c.Params("attendeeId"), reservation.ID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attendee not found"})
+	}
+
+	if attendee.UserID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Attendee has already claimed the seat"})
+	}
+
+	if err := ac.DB.Delete(&attendee).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove attendee"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Attendee removed"})
+}
+
+// ResendClaimLink issues a fresh claim link, invalidating the previous one
+// POST /api/reservations/:id/attendees/:attendeeId/resend
+func (ac *AttendeeController) ResendClaimLink(c *fiber.Ctx) error {
+	reservation, errResp := ac.loadOwnReservation(c, ac.DB)
+	if reservation == nil {
+		return errResp
+	}
+
+	var attendee models.ReservationAttendee
+	if err := ac.DB.First(&attendee, "id = ? AND reservation_id = ?", c.Params("attendeeId"), reservation.ID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attendee not found"})
+	}
+
+	if attendee.UserID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Attendee has already claimed the seat"})
+	}
+
+	token, err := issueClaimToken(&attendee)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create claim link"})
+	}
+	if err := ac.DB.Model(&attendee).Updates(map[string]interface{}{
+		"claim_token_hash":       attendee.ClaimTokenHash,
+		"claim_token_expires_at": attendee.ClaimTokenExpiresAt,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create claim link"})
+	}
+
+	ac.sendClaimEmail(&attendee, reservation, token)
+
+	return c.JSON(fiber.Map{"message": "Claim link sent"})
+}
+
+// ClaimSeat links an attendee seat to the logged-in account
+// POST /api/attendees/claim
+func (ac *AttendeeController) ClaimSeat(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input ClaimAttendeeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	tx := ac.DB.Begin()
+
+	var attendee models.ReservationAttendee
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("claim_token_hash = ?", utils.HashAPIKey(input.Token)).
+		First(&attendee).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Invalid or expired claim link"})
+	}
+
+	if attendee.ClaimTokenExpiresAt == nil || time.Now().After(*attendee.ClaimTokenExpiresAt) {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Invalid or expired claim link"})
+	}
+
+	var reservation models.Reservation
+	if err := tx.First(&reservation, "id = ?", attendee.ReservationID).Error; err != nil || !domain.HoldsSeat(reservation.Status) {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Reservation is no longer active"})
+	}
+
+	var alreadyClaimed int64
+	if err := tx.Model(&models.ReservationAttendee{}).
+		Where("reservation_id = ? AND user_id = ?", reservation.ID, userID).
+		Count(&alreadyClaimed).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to claim seat"})
+	}
+	if alreadyClaimed > 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You already hold a seat in this reservation"})
+	}
+
+	now := time.Now()
+	if err := tx.Model(&attendee).Updates(map[string]interface{}{
+		"user_id":                userID,
+		"claimed_at":             now,
+		"claim_token_hash":       "",
+		"claim_token_expires_at": nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to claim seat"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to claim seat"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Seat claimed",
+		"attendee_id":    attendee.ID,
+		"reservation_id": reservation.ID,
+	})
+}
+
+// GetMySeats lists seats claimed by the logged-in user in other people's reservations
+// GET /api/attendees/my
+func (ac *AttendeeController) GetMySeats(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var attendees []models.ReservationAttendee
+	if err := ac.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&attendees).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch seats"})
+	}
+
+	reservationIDs := make([]string, 0, len(attendees))
+	for _, a := range attendees {
+		reservationIDs = append(reservationIDs, a.ReservationID)
+	}
+
+	var reservations []models.Reservation
+	if len(reservationIDs) > 0 {
+		if err := ac.DB.Preload("Court").Preload("Schedule").Where("id IN ?", reservationIDs).Find(&reservations).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch seats"})
+		}
+	}
+	reservationsByID := make(map[string]*models.Reservation, len(reservations))
+	for i := range reservations {
+		reservationsByID[reservations[i].ID] = &reservations[i]
+	}
+
+	seats := []fiber.Map{}
+	for _, a := range attendees {
+		reservation, ok := reservationsByID[a.ReservationID]
+		if !ok {
+			continue
+		}
+		seats = append(seats, fiber.Map{
+			"attendee_id":    a.ID,
+			"reservation_id": reservation.ID,
+			"status":         reservation.Status,
+			"waiver_status":  a.WaiverStatus,
+			"court":          reservation.Court.Name,
+			"date":           reservation.Schedule.Date.Format("2006-01-02"),
+			"start_time":     reservation.Schedule.StartTime,
+			"end_time":       reservation.Schedule.EndTime,
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": seats})
+}
+
+// SignWaiver records the waiver for a seat the caller has claimed
+// POST /api/attendees/:id/waiver
+func (ac *AttendeeController) SignWaiver(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var attendee models.ReservationAttendee
+	if err := ac.DB.First(&attendee, "id = ? AND user_id = ?", c.Params("id"), userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attendee not found"})
+	}
+
+	return ac.setWaiver(c, &attendee, models.WaiverSigned)
+}
+
+// AdminUpdateWaiver sets the waiver status, e.g. for paper waivers signed at the front desk
+// PUT /api/admin/attendees/:id/waiver
+func (ac *AttendeeController) AdminUpdateWaiver(c *fiber.Ctx) error {
+	var input UpdateWaiverInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	var attendee models.ReservationAttendee
+	if err := ac.DB.First(&attendee, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attendee not found"})
+	}
+
+	return ac.setWaiver(c, &attendee, input.Status)
+}
+
+func (ac *AttendeeController) setWaiver(c *fiber.Ctx, attendee *models.ReservationAttendee, status string) error {
+	var signedAt *time.Time
+	if status == models.WaiverSigned {
+		now := time.Now()
+		signedAt = &now
+	}
+
+	if err := ac.DB.Model(attendee).Updates(map[string]interface{}{
+		"waiver_status":    status,
+		"waiver_signed_at": signedAt,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update waiver"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Waiver updated", "waiver_status": status})
+}
+
+// issueClaimToken sets a new claim token hash on a and returns the raw token
+func issueClaimToken(a *models.ReservationAttendee) (string, error) {
+	token, err := services.RandomURLSafe(32)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(attendeeClaimTTL)
+	a.ClaimTokenHash = utils.HashAPIKey(token)
+	a.ClaimTokenExpiresAt = &expiresAt
+	return token, nil
+}
+
+// sendClaimEmail is best effort: the booker can always resend the link
+func (ac *AttendeeController) sendClaimEmail(a *models.ReservationAttendee, r *models.Reservation, token string) {
+	var schedule models.Schedule
+	ac.DB.Preload("Court").First(&schedule, "id = ?", r.ScheduleID)
+
+	var booker models.User
+	ac.DB.First(&booker, "id = ?", r.UserID)
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\n%s booked you a seat at Diro Pilates:\n%s, %s %s - %s\n\nClaim your seat to see it in your account and sign the waiver:\n%s/attendees/claim?token=%s\n\nThis link expires in 7 days.\n",
+		a.Name, booker.Name, schedule.Court.Name, schedule.Date.Format("Mon 02 Jan 2006"),
+		schedule.StartTime, schedule.EndTime, frontendURL(), token,
+	)
+
+	if err := ac.Mailer.Send(a.Email, "You have a seat at Diro Pilates", body); err != nil {
+		log.Printf("Failed to send claim email for attendee %s: %v", a.ID, err)
+	}
+}
@@ -167,5 +167,6 @@ func (ac *AuthController) Me(c *fiber.Ctx) error {
 			"email": user.Email,
 			"role":  user.Role,
 		},
+		"impersonated_by": c.Locals("impersonator_id"),
 	})
 }
@@ -0,0 +1,451 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+// Outcomes of cancelling a reservation that clashes with a blackout
+const (
+	BlackoutOutcomeCancelled     = "cancelled"
+	BlackoutOutcomeRefunded      = "refunded"
+	BlackoutOutcomeRefundManual  = "cancelled_refund_manual" // paid in cash, refund at the front desk
+	BlackoutOutcomeRefundFailed  = "refund_failed"           // reservation left as it was; top-ups already paid back stay recorded
+	BlackoutOutcomeFailed        = "failed"
+	BlackoutOutcomeNotConflicted = "not_in_blackout"
+)
+
+type BlackoutController struct {
+	DB       *gorm.DB
+	Midtrans *services.MidtransService
+}
+
+func NewBlackoutController(db *gorm.DB, mt *services.MidtransService) *BlackoutController {
+	return &BlackoutController{DB: db, Midtrans: mt}
+}
+
+type CreateBlackoutInput struct {
+	CourtID  *string `json:"court_id" validate:"omitempty,uuid"` // empty closes the whole studio
+	StartsAt string  `json:"starts_at" validate:"required"`      // RFC 3339, or YYYY-MM-DDTHH:MM in studio time
+	EndsAt   string  `json:"ends_at" validate:"required"`
+	Reason   string  `json:"reason" validate:"max=200"`
+}
+
+type CancelBlackoutReservationsInput struct {
+	ReservationIDs []string `json:"reservation_ids" validate:"omitempty,dive,uuid"` // empty for every conflict
+	Refund         *bool    `json:"refund"`                                         // default true
+}
+
+// parseStudioTime accepts RFC 3339 or a local date-time in the studio timezone
+func parseStudioTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, utils.StudioLocation()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// blackoutConflicts returns the active reservations on schedules inside b
+func (bc *BlackoutController) blackoutConflicts(b *models.Blackout) ([]models.Reservation, error) {
+	schedules, err := domain.SchedulesInBlackout(bc.DB, b)
+	if err != nil {
+		return nil, err
+	}
+
+	reservations := []models.Reservation{}
+	if len(schedules) == 0 {
+		return reservations, nil
+	}
+
+	ids := make([]string, len(schedules))
+	for i, s := range schedules {
+		ids[i] = s.ID
+	}
+
+	err = bc.DB.Preload("User").Preload("Court").Preload("Schedule").
+		Where("schedule_id IN ? AND status IN ?", ids, domain.ActiveReservationStatuses).
+		Order("created_at ASC").
+		Find(&reservations).Error
+	return reservations, err
+}
+
+func conflictResponse(reservations []models.Reservation) []fiber.Map {
+	conflicts := []fiber.Map{}
+	for _, r := range reservations {
+		conflicts = append(conflicts, fiber.Map{
+			"reservation_id": r.ID,
+			"status":         r.Status,
+			"seats":          r.Seats,
+			"total_amount":   r.TotalAmount,
+			"customer":       r.User.Name,
+			"email":          r.User.Email,
+			"schedule_id":    r.ScheduleID,
+			"court_name":     r.Court.Name,
+			"starts_at":      r.Schedule.StartsAt(),
+			"ends_at":        r.Schedule.EndsAt(),
+		})
+	}
+	return conflicts
+}
+
+// GetBlackouts lists blackouts that have not ended, or all with ?all=true
+// GET /api/admin/blackouts
+func (bc *BlackoutController) GetBlackouts(c *fiber.Ctx) error {
+	db := bc.DB.Preload("Court").Order("starts_at ASC")
+	if c.Query("all") != "true" {
+		db = db.Where("ends_at > ?", time.Now())
+	}
+
+	var blackouts []models.Blackout
+	if err := db.Find(&blackouts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch blackouts"})
+	}
+
+	return c.JSON(fiber.Map{"data": blackouts})
+}
+
+// CreateBlackout closes every schedule in the period and reports the
+// reservations already booked on them. Those stay untouched until the admin
+// cancels them through CancelBlackoutReservations.
+// POST /api/admin/blackouts
+func (bc *BlackoutController) CreateBlackout(c *fiber.Ctx) error {
+	var input CreateBlackoutInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	startsAt, err := parseStudioTime(input.StartsAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	endsAt, err := parseStudioTime(input.EndsAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !endsAt.After(startsAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "End must be after start"})
+	}
+
+	if input.CourtID != nil {
+		var court models.Court
+		if err := bc.DB.First(&court, "id = ?", *input.CourtID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Court not found"})
+		}
+	}
+
+	blackout := models.Blackout{
+		CourtID:   input.CourtID,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		Reason:    input.Reason,
+		CreatedBy: c.Locals("user_id").(string),
+	}
+
+	var closed []string
+	err = bc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&blackout).Error; err != nil {
+			return err
+		}
+
+		schedules, err := domain.SchedulesInBlackout(tx, &blackout)
+		if err != nil {
+			return err
+		}
+		for _, s := range schedules {
+			if s.IsAvailable {
+				closed = append(closed, s.ID)
+			}
+		}
+		if len(closed) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.Schedule{}).Where("id IN ?", closed).Update("is_available", false).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.ScheduleChanged, blackout.ID, events.ScheduleChangedPayload{
+			Action:      "closed",
+			ScheduleIDs: closed,
+			CourtID:     stringValue(blackout.CourtID),
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create blackout"})
+	}
+
+	conflicts, err := bc.blackoutConflicts(&blackout)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Blackout created but conflicts could not be checked"})
+	}
+
+	services.RecordAudit(bc.DB, services.AuditEntry{
+		UserID:    blackout.CreatedBy,
+		Action:    "blackout_created",
+		TableName: "blackouts",
+		RecordID:  blackout.ID,
+		NewData:   blackout,
+		IPAddress: c.IP(),
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data":             blackout,
+		"closed_schedules": len(closed),
+		"conflicts":        conflictResponse(conflicts),
+	})
+}
+
+// GetBlackoutConflicts lists the active reservations inside a blackout
+// GET /api/admin/blackouts/:id/conflicts
+func (bc *BlackoutController) GetBlackoutConflicts(c *fiber.Ctx) error {
+	var blackout models.Blackout
+	if err := bc.DB.First(&blackout, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blackout not found"})
+	}
+
+	conflicts, err := bc.blackoutConflicts(&blackout)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not check conflicts"})
+	}
+
+	return c.JSON(fiber.Map{"data": conflictResponse(conflicts)})
+}
+
+// CancelBlackoutReservations cancels the reservations inside a blackout and
+// refunds the ones paid online in full. Each reservation is handled on its
+// own, so one failed refund does not stop the rest.
+// POST /api/admin/blackouts/:id/cancel-reservations
+func (bc *BlackoutController) CancelBlackoutReservations(c *fiber.Ctx) error {
+	var input CancelBlackoutReservationsInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	refund := input.Refund == nil || *input.Refund
+
+	var blackout models.Blackout
+	if err := bc.DB.First(&blackout, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blackout not found"})
+	}
+
+	conflicts, err := bc.blackoutConflicts(&blackout)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not check conflicts"})
+	}
+
+	targets := conflicts
+	results := []fiber.Map{}
+	if len(input.ReservationIDs) > 0 {
+		byID := map[string]models.Reservation{}
+		for _, r := range conflicts {
+			byID[r.ID] = r
+		}
+		targets = nil
+		for _, id := range input.ReservationIDs {
+			r, ok := byID[id]
+			if !ok {
+				results = append(results, fiber.Map{"reservation_id": id, "outcome": BlackoutOutcomeNotConflicted})
+				continue
+			}
+			targets = append(targets, r)
+		}
+	}
+
+	actor := actorFromContext(c, domain.SourceAdmin)
+	summary := map[string]int{}
+	for i := range targets {
+		outcome, err := bc.cancelForBlackout(&targets[i], &blackout, actor, refund)
+		result := fiber.Map{"reservation_id": targets[i].ID, "outcome": outcome}
+		if err != nil {
+			result["error"] = err.Error()
+		}
+		results = append(results, result)
+		summary[outcome]++
+	}
+
+	services.RecordAudit(bc.DB, services.AuditEntry{
+		UserID:         actor.UserID,
+		ImpersonatorID: actor.ImpersonatorID,
+		Action:         "blackout_reservations_cancelled",
+		TableName:      "blackouts",
+		RecordID:       blackout.ID,
+		NewData:        fiber.Map{"refund": refund, "summary": summary},
+		IPAddress:      c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"results": results, "summary": summary})
+}
+
+// cancelForBlackout refunds a reservation paid online (which moves it to
+// refunded) or cancels it otherwise. Each payment of the reservation, its
+// booking and any reschedule top-ups, is refunded on its own for what is left
+// of it, so a cart order sharing one Midtrans transaction is only partially
+// refunded. The gateway refunds happen before the database change; their
+// refund keys make a retry safe.
+func (bc *BlackoutController) cancelForBlackout(r *models.Reservation, b *models.Blackout, actor domain.Actor, refund bool) (string, error) {
+	var payments []models.Payment
+	bc.DB.Where("reservation_id = ? AND status = ?", r.ID, domain.PaymentSuccess).
+		Order("created_at ASC").Find(&payments)
+
+	// Top-ups first and the booking last, so the reservation only moves to
+	// refunded once everything else has been paid back
+	var booking *models.Payment
+	online := []*models.Payment{}
+	for i := range payments {
+		p := &payments[i]
+		if domain.IsBookingPayment(p) {
+			booking = p
+		} else if p.PaymentMethod != "manual_cash" {
+			online = append(online, p)
+		}
+	}
+	if booking != nil && booking.PaymentMethod != "manual_cash" {
+		online = append(online, booking)
+	}
+
+	paidOnline := r.Status == domain.ReservationPaid && booking != nil && booking.PaymentMethod != "manual_cash"
+
+	if refund && paidOnline {
+		reason := "Studio closed"
+		if b.Reason != "" {
+			reason = "Studio closed: " + b.Reason
+		}
+
+		var refunded []*models.Payment
+		var refundErr error
+		for _, p := range online {
+			amount, err := domain.RefundableAmount(bc.DB, p)
+			if err != nil {
+				refundErr = err
+				break
+			}
+			// The booking keeps its original key so earlier attempts stay idempotent
+			key := "blackout-" + p.ID
+			if p == booking {
+				key = "blackout-" + r.ID
+			}
+			if amount > 0 {
+				if err := bc.Midtrans.RefundTransaction(p.OrderID(), amount, key, reason); err != nil {
+					log.Printf("Blackout refund of payment %s for reservation %s failed: %v", p.ID, r.ID, err)
+					refundErr = err
+					break
+				}
+			}
+			refunded = append(refunded, p)
+		}
+
+		// Record what went through even if a later refund failed
+		tx := domain.WithActor(bc.DB.Begin(), actor)
+		for _, p := range refunded {
+			if err := domain.ApplyPaymentStatus(tx, p, domain.PaymentRefunded); err != nil {
+				tx.Rollback()
+				// The money has moved: leave a trace for manual follow-up
+				log.Printf("Reservation %s refunded at the gateway but not recorded: %v", r.ID, err)
+				return BlackoutOutcomeFailed, err
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return BlackoutOutcomeFailed, err
+		}
+
+		if refundErr != nil {
+			return BlackoutOutcomeRefundFailed, refundErr
+		}
+		return BlackoutOutcomeRefunded, nil
+	}
+
+	tx := domain.WithActor(bc.DB.Begin(), actor)
+	if err := domain.TransitionReservation(tx, r, domain.ReservationCancelled); err != nil {
+		tx.Rollback()
+		return BlackoutOutcomeFailed, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return BlackoutOutcomeFailed, err
+	}
+
+	if refund && r.Status == domain.ReservationCancelled && len(payments) > 0 {
+		return BlackoutOutcomeRefundManual, nil
+	}
+	return BlackoutOutcomeCancelled, nil
+}
+
+// DeleteBlackout removes a blackout and reopens its schedules that still
+// have seats and are not covered by another blackout
+// DELETE /api/admin/blackouts/:id
+func (bc *BlackoutController) DeleteBlackout(c *fiber.Ctx) error {
+	var blackout models.Blackout
+	if err := bc.DB.First(&blackout, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blackout not found"})
+	}
+
+	err := bc.DB.Transaction(func(tx *gorm.DB) error {
+		schedules, err := domain.SchedulesInBlackout(tx, &blackout)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&blackout).Error; err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(schedules))
+		for _, s := range schedules {
+			if err := domain.SyncScheduleAvailability(tx, s.ID); err != nil {
+				return err
+			}
+			ids = append(ids, s.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return events.Publish(tx, events.ScheduleChanged, blackout.ID, events.ScheduleChangedPayload{
+			Action:      "reopened",
+			ScheduleIDs: ids,
+			CourtID:     stringValue(blackout.CourtID),
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete blackout"})
+	}
+
+	services.RecordAudit(bc.DB, services.AuditEntry{
+		UserID:    c.Locals("user_id").(string),
+		Action:    "blackout_deleted",
+		TableName: "blackouts",
+		RecordID:  blackout.ID,
+		OldData:   blackout,
+		IPAddress: c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"message": "Blackout deleted"})
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	// calendarPastDays keeps recent sessions in feeds so calendars do not
+	// drop them the moment they end
+	calendarPastDays = 30
+	// studioFeedDays is how far ahead the public studio feed reaches
+	studioFeedDays = 90
+)
+
+type CalendarController struct {
+	DB *gorm.DB
+}
+
+func NewCalendarController(db *gorm.DB) *CalendarController {
+	return &CalendarController{DB: db}
+}
+
+func sendICal(c *fiber.Ctx, filename, body string) error {
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s"`, filename))
+	return c.SendString(body)
+}
+
+// CreateCalendarToken issues the secret URL of the personal calendar feed.
+// Calling it again rotates the token, which stops the old URL working.
+// POST /api/calendar/token
+func (cc *CalendarController) CreateCalendarToken(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	token, err := services.RandomURLSafe(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create calendar token"})
+	}
+
+	hash := utils.HashAPIKey(token)
+	if err := cc.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token_hash", hash).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create calendar token"})
+	}
+
+	services.RecordAudit(cc.DB, services.AuditEntry{
+		UserID:    userID,
+		Action:    "calendar_token_created",
+		TableName: "users",
+		RecordID:  userID,
+		IPAddress: c.IP(),
+	})
+
+	// The token is only shown once
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"feed_url": c.BaseURL() + "/api/calendar/feed/" + token,
+	})
+}
+
+// RevokeCalendarToken disables the personal calendar feed
+// DELETE /api/calendar/token
+func (cc *CalendarController) RevokeCalendarToken(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	if err := cc.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token_hash", nil).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not revoke calendar token"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Calendar feed disabled"})
+}
+
+// GetUserFeed serves the reservations of the token's owner. Calendar apps
+// cannot send our JWT, so the secret token in the URL is the credential.
+// GET /api/calendar/feed/:token
+func (cc *CalendarController) GetUserFeed(c *fiber.Ctx) error {
+	var user models.User
+	if err := cc.DB.Where("calendar_token_hash = ? AND anonymized_at IS NULL", utils.HashAPIKey(c.Params("token"))).
+		First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Calendar feed not found"})
+	}
+
+	since := utils.StudioNow().AddDate(0, 0, -calendarPastDays).Format("2006-01-02")
+
+	var reservations []models.Reservation
+	if err := cc.DB.Preload("Court").Preload("Schedule").
+		Joins("JOIN schedules ON schedules.id = reservations.schedule_id").
+		Where("reservations.user_id = ? AND schedules.date >= ?", user.ID, since).
+		Order("schedules.date ASC, schedules.start_time ASC").
+		Find(&reservations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not build calendar"})
+	}
+
+	icalEvents := make([]services.ICalEvent, 0, len(reservations))
+	for i := range reservations {
+		icalEvents = append(icalEvents, services.ReservationICalEvent(&reservations[i]))
+	}
+
+	return sendICal(c, "diro-pilates.ics", services.RenderICal("Diro Pilates", icalEvents))
+}
+
+// GetStudioFeed serves the public class schedule, optionally for one court
+// GET /api/calendar/studio.ics?court_id=
+func (cc *CalendarController) GetStudioFeed(c *fiber.Ctx) error {
+	now := utils.StudioNow()
+	query := cc.DB.Preload("Court").
+		Joins("JOIN courts ON courts.id = schedules.court_id").
+		Where("schedules.is_private = ? AND courts.is_active = ?", false, true).
+		Where("schedules.date BETWEEN ? AND ?",
+			now.AddDate(0, 0, -calendarPastDays).Format("2006-01-02"),
+			now.AddDate(0, 0, studioFeedDays).Format("2006-01-02"))
+
+	name := "Diro Pilates - Schedule"
+	if courtID := c.Query("court_id"); courtID != "" {
+		var court models.Court
+		if err := cc.DB.First(&court, "id = ?", courtID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Court not found"})
+		}
+		query = query.Where("schedules.court_id = ?", court.ID)
+		name = "Diro Pilates - " + court.Name
+	}
+
+	var schedules []models.Schedule
+	if err := query.Order("schedules.date ASC, schedules.start_time ASC").Find(&schedules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not build calendar"})
+	}
+
+	icalEvents := make([]services.ICalEvent, 0, len(schedules))
+	for i := range schedules {
+		icalEvents = append(icalEvents, services.ScheduleICalEvent(&schedules[i]))
+	}
+
+	return sendICal(c, "diro-pilates-schedule.ics", services.RenderICal(name, icalEvents))
+}
+
+// DownloadReservation returns a single reservation as an .ics attachment.
+// Its UID matches the personal feed, so importing both does not duplicate it.
+// GET /api/reservations/:id/calendar.ics
+func (cc *CalendarController) DownloadReservation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var reservation models.Reservation
+	if err := cc.DB.Preload("Court").Preload("Schedule").
+		Where("id = ? AND user_id = ?", c.Params("id"), userID).
+		First(&reservation).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
+	}
+
+	body := services.RenderICal("Diro Pilates", []services.ICalEvent{services.ReservationICalEvent(&reservation)})
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="reservation-%s.ics"`, reservation.ID))
+	return c.SendString(body)
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+type CartItemInput struct {
+	ScheduleID string `json:"schedule_id" validate:"required,uuid"`
+	Seats      int    `json:"seats" validate:"required,min=1,max=20"`
+}
+
+type CreateCartReservationInput struct {
+	Items []CartItemInput `json:"items" validate:"required,min=1,max=10,dive"`
+	Notes string          `json:"notes"`
+}
+
+// CreateCartReservation books several slots and/or seats in one checkout.
+// All reservations share one Snap transaction, so they are paid, or released, together.
+// POST /api/reservations/cart
+func (rc *ReservationController) CreateCartReservation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	var input CreateCartReservationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	// Merge repeated schedules and sort, so seats are always locked in the
+	// same order and concurrent carts cannot deadlock
+	seatsBySchedule := map[string]int{}
+	for _, item := range input.Items {
+		seatsBySchedule[item.ScheduleID] += item.Seats
+	}
+	scheduleIDs := make([]string, 0, len(seatsBySchedule))
+	for id := range seatsBySchedule {
+		scheduleIDs = append(scheduleIDs, id)
+	}
+	sort.Strings(scheduleIDs)
+
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceUser))
+
+	// 1. Lock every schedule and check its remaining seats
+	schedules := make([]models.Schedule, len(scheduleIDs))
+	for i, id := range scheduleIDs {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Court").First(&schedules[i], "id = ?", id).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found", "schedule_id": id})
+		}
+
+		if !schedules[i].IsAvailable {
+			tx.Rollback()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Schedule is no longer available", "schedule_id": id})
+		}
+
+		booked, err := domain.BookedSeats(tx, id)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check capacity"})
+		}
+		if remaining := schedules[i].SeatCapacity(schedules[i].Court) - booked; seatsBySchedule[id] > remaining {
+			tx.Rollback()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":           "Not enough seats left",
+				"schedule_id":     id,
+				"seats_remaining": remaining,
+			})
+		}
+	}
+
+	// 2. Get User info for payment details
+	var user models.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "User data error"})
+	}
+
+	// 3. Create the booking and one reservation per schedule
+	booking := models.Booking{UserID: userID, Notes: input.Notes}
+	for _, s := range schedules {
+		booking.TotalAmount += s.SlotPrice(s.Court) * float64(seatsBySchedule[s.ID])
+	}
+	if err := tx.Create(&booking).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create booking"})
+	}
+
+	reservations := make([]models.Reservation, len(schedules))
+	payments := make([]models.Payment, len(schedules))
+	lines := make([]services.CartLine, len(schedules))
+	for i, s := range schedules {
+		seats := seatsBySchedule[s.ID]
+		reservations[i] = models.Reservation{
+			UserID:      userID,
+			CourtID:     s.CourtID,
+			ScheduleID:  s.ID,
+			BookingID:   &booking.ID,
+			Seats:       seats,
+			Status:      domain.ReservationPending,
+			TotalAmount: s.SlotPrice(s.Court) * float64(seats),
+			Notes:       input.Notes,
+		}
+		if err := tx.Create(&reservations[i]).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+		}
+
+		payload := events.NewReservationPayload(&reservations[i], "")
+		if err := events.Publish(tx, events.ReservationCreated, reservations[i].ID, payload); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+		}
+		if err := domain.RecordReservationEvent(tx, reservations[i].ID, domain.TimelineCreated, "", domain.ReservationPending, payload); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+		}
+
+		if err := domain.SyncScheduleAvailability(tx, s.ID); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to lock schedule"})
+		}
+
+		// 4. One pending payment per reservation, all under the booking's Snap order
+		payments[i] = models.Payment{
+			ReservationID:   reservations[i].ID,
+			MidtransOrderID: fmt.Sprintf("%s-%d", booking.ID, i+1),
+			GatewayOrderID:  &booking.ID,
+			Amount:          reservations[i].TotalAmount,
+			Status:          domain.PaymentPending,
+		}
+		if err := tx.Create(&payments[i]).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to init payment"})
+		}
+
+		lines[i] = services.CartLine{
+			ScheduleID: s.ID,
+			Name:       fmt.Sprintf("%s %s %s", s.Court.Name, s.Date.Format("02 Jan"), s.StartTime),
+			Price:      s.SlotPrice(s.Court),
+			Seats:      seats,
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create booking"})
+	}
+
+	// 5. Generate one Midtrans Snap Token with a line item per slot, after
+	// commit so the schedule locks are not held during the call
+	token, redirectURL, err := rc.Midtrans.GenerateCartSnapToken(booking.ID, lines, &user)
+	if err != nil {
+		fmt.Println("Midtrans Error:", err)
+		rc.abandonCart(c, payments)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate payment token"})
+	}
+
+	reservationIDs := make([]string, len(reservations))
+	for i, r := range reservations {
+		reservationIDs[i] = r.ID
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":         "Booking created",
+		"booking_id":      booking.ID,
+		"reservation_ids": reservationIDs,
+		"snap_token":      token,
+		"redirect_url":    redirectURL,
+		"amount":          booking.TotalAmount,
+	})
+}
+
+// abandonCart fails the payments of a cart whose Snap token could not be
+// created, which cancels its reservations and frees the seats
+func (rc *ReservationController) abandonCart(c *fiber.Ctx, payments []models.Payment) {
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceUser))
+
+	for i := range payments {
+		if err := domain.ApplyPaymentStatus(tx, &payments[i], domain.PaymentFailed); err != nil {
+			tx.Rollback()
+			log.Printf("Failed to release cart of payment %s: %v", payments[i].ID, err)
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Failed to release cart: %v", err)
+	}
+}
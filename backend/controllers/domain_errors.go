@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+)
+
+// transitionErrorResponse answers 409 for transitions the state machine
+// rejects and 500 with fallback for anything else
+func transitionErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	if errors.Is(err, domain.ErrIllegalTransition) || errors.Is(err, domain.ErrGuardFailed) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
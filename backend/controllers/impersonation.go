@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	defaultImpersonationMinutes = 30
+	maxImpersonationMinutes     = 120
+)
+
+type ImpersonationController struct {
+	DB *gorm.DB
+}
+
+func NewImpersonationController(db *gorm.DB) *ImpersonationController {
+	return &ImpersonationController{DB: db}
+}
+
+type StartImpersonationInput struct {
+	DurationMinutes int    `json:"duration_minutes" validate:"omitempty,min=1"` // at most maxImpersonationMinutes
+	Reason          string `json:"reason" validate:"required,min=5"`
+}
+
+// StartImpersonation issues a time-limited token for the target user.
+// The admin's own token is kept in a separate cookie so the session can be restored.
+func (ic *ImpersonationController) StartImpersonation(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(string)
+	targetID := c.Params("id")
+
+	var input StartImpersonationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	if input.DurationMinutes == 0 {
+		input.DurationMinutes = defaultImpersonationMinutes
+	}
+	if input.DurationMinutes > maxImpersonationMinutes {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("duration_minutes must be at most %d", maxImpersonationMinutes)})
+	}
+
+	var target models.User
+	if err := ic.DB.First(&target, "id = ?", targetID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if target.ID == adminID || target.Role == "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admins cannot be impersonated"})
+	}
+
+	ttl := time.Duration(input.DurationMinutes) * time.Minute
+	token, err := utils.GenerateImpersonationToken(target.ID, target.Role, adminID, ttl)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate token"})
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	// Keep the admin session aside so StopImpersonation can restore it
+	adminToken := c.Cookies("token")
+	if adminToken != "" {
+		c.Cookie(&fiber.Cookie{
+			Name:     "admin_token",
+			Value:    adminToken,
+			Expires:  expiresAt,
+			HTTPOnly: true,
+			Secure:   false, // Set to true in production
+			SameSite: "Lax",
+		})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    token,
+		Expires:  expiresAt,
+		HTTPOnly: true,
+		Secure:   false, // Set to true in production
+		SameSite: "Lax",
+	})
+
+	services.RecordAudit(ic.DB, services.AuditEntry{
+		UserID:         target.ID,
+		ImpersonatorID: adminID,
+		Action:         "impersonation_started",
+		TableName:      "users",
+		RecordID:       target.ID,
+		NewData: fiber.Map{
+			"reason":     input.Reason,
+			"expires_at": expiresAt,
+		},
+		IPAddress: c.IP(),
+	})
+
+	response := fiber.Map{
+		"message":    "Impersonation started",
+		"expires_at": expiresAt,
+		"user": fiber.Map{
+			"id":    target.ID,
+			"name":  target.Name,
+			"email": target.Email,
+			"role":  target.Role,
+		},
+	}
+	// Browser sessions get the token as a cookie only; clients that sent a
+	// bearer token have no cookie jar and need it in the body
+	if adminToken == "" {
+		response["token"] = token
+	}
+	return c.JSON(response)
+}
+
+// StopImpersonation ends the session and restores the admin's own token if it was kept in a cookie
+func (ic *ImpersonationController) StopImpersonation(c *fiber.Ctx) error {
+	impersonatorID, ok := c.Locals("impersonator_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Not impersonating"})
+	}
+	userID := c.Locals("user_id").(string)
+
+	services.RecordAudit(ic.DB, services.AuditEntry{
+		UserID:         userID,
+		ImpersonatorID: impersonatorID,
+		Action:         "impersonation_stopped",
+		TableName:      "users",
+		RecordID:       userID,
+		IPAddress:      c.IP(),
+	})
+
+	// Restore admin session only if the stored token still belongs to the impersonator
+	restored := false
+	if adminToken := c.Cookies("admin_token"); adminToken != "" {
+		if token, err := utils.ParseToken(adminToken); err == nil && token.Valid {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok && claims["user_id"] == impersonatorID {
+				c.Cookie(&fiber.Cookie{
+					Name:     "token",
+					Value:    adminToken,
+					Expires:  time.Now().Add(time.Hour * 72),
+					HTTPOnly: true,
+					Secure:   false,
+					SameSite: "Lax",
+				})
+				restored = true
+			}
+		}
+	}
+
+	if !restored {
+		c.Cookie(&fiber.Cookie{
+			Name:     "token",
+			Value:    "",
+			Expires:  time.Now().Add(-time.Hour),
+			HTTPOnly: true,
+			Secure:   false,
+			SameSite: "Lax",
+		})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "admin_token",
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+
+	return c.JSON(fiber.Map{
+		"message":          "Impersonation stopped",
+		"session_restored": restored,
+	})
+}
+
+// GetAuditLogs lists audit trail entries, optionally filtered by user or impersonator
+func (ic *ImpersonationController) GetAuditLogs(c *fiber.Ctx) error {
+	db := ic.DB.Order("created_at DESC").Limit(200)
+
+	if userID := c.Query("user_id"); userID != "" {
+		db = db.Where("user_id = ?", userID)
+	}
+	if impersonatorID := c.Query("impersonator_id"); impersonatorID != "" {
+		db = db.Where("impersonator_id = ?", impersonatorID)
+	}
+	if action := c.Query("action"); action != "" {
+		db = db.Where("action = ?", action)
+	}
+
+	var logs []models.AuditLog
+	if err := db.Find(&logs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch audit logs"})
+	}
+
+	return c.JSON(fiber.Map{"data": logs})
+}
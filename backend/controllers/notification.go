@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+type NotificationController struct {
+	DB       *gorm.DB
+	Notifier *services.Notifier
+}
+
+func NewNotificationController(db *gorm.DB, notifier *services.Notifier) *NotificationController {
+	return &NotificationController{DB: db, Notifier: notifier}
+}
+
+type ChannelPreferenceInput struct {
+	Channel string `json:"channel" validate:"required"`
+	Enabled bool   `json:"enabled"`
+}
+
+type UpdatePreferencesInput struct {
+	Channels []ChannelPreferenceInput `json:"channels" validate:"required,min=1,dive"`
+}
+
+// GetPreferences lists every channel and whether the user receives notifications on it
+// GET /api/notifications/preferences
+func (nc *NotificationController) GetPreferences(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	enabled, err := nc.Notifier.EnabledChannels(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch preferences"})
+	}
+
+	channels := []fiber.Map{}
+	for _, ch := range nc.Notifier.Channels() {
+		channels = append(channels, fiber.Map{"channel": ch.Name(), "enabled": enabled[ch.Name()]})
+	}
+
+	return c.JSON(fiber.Map{"channels": channels})
+}
+
+// UpdatePreferences turns channels on or off
+// PUT /api/notifications/preferences
+func (nc *NotificationController) UpdatePreferences(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input UpdatePreferencesInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	var user models.User
+	if err := nc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	for _, p := range input.Channels {
+		ch := nc.Notifier.Channel(p.Channel)
+		if ch == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown channel: " + p.Channel})
+		}
+		// e.g. WhatsApp needs a verified phone number first
+		if p.Enabled && ch.Address(&user) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No verified address for channel: " + p.Channel})
+		}
+	}
+
+	err := nc.DB.Transaction(func(tx *gorm.DB) error {
+		for _, p := range input.Channels {
+			pref := models.NotificationPreference{UserID: userID, Channel: p.Channel, Enabled: p.Enabled}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}},
+				DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+			}).Create(&pref).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update preferences"})
+	}
+
+	return nc.GetPreferences(c)
+}
+
+// GetDeliveries lists the notification delivery log for admins
+// GET /api/admin/notifications/deliveries
+func (nc *NotificationController) GetDeliveries(c *fiber.Ctx) error {
+	db := nc.DB.Order("created_at DESC").Limit(200)
+
+	if userID := c.Query("user_id"); userID != "" {
+		db = db.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		db = db.Where("channel = ?", channel)
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := db.Find(&deliveries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch deliveries"})
+	}
+
+	return c.JSON(fiber.Map{"data": deliveries})
+}
+
+func notificationResponse(n *models.Notification) fiber.Map {
+	entry := fiber.Map{
+		"id":         n.ID,
+		"type":       n.Type,
+		"title":      n.Title,
+		"body":       n.Body,
+		"read":       n.ReadAt != nil,
+		"read_at":    n.ReadAt,
+		"created_at": n.CreatedAt,
+	}
+	if len(n.Data) > 0 {
+		entry["data"] = json.RawMessage(n.Data)
+	}
+	return entry
+}
+
+// GetNotifications returns a page of the user's inbox, newest first, with the unread count
+// GET /api/notifications?page=1&limit=20&unread=true
+func (nc *NotificationController) GetNotifications(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := nc.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch notifications"})
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&notifications).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch notifications"})
+	}
+
+	var unread int64
+	nc.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	data := make([]fiber.Map, 0, len(notifications))
+	for i := range notifications {
+		data = append(data, notificationResponse(&notifications[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"data":         data,
+		"unread_count": unread,
+		"page":         page,
+		"limit":        limit,
+		"total":        total,
+	})
+}
+
+// MarkNotificationRead marks one notification as read
+// POST /api/notifications/:id/read
+func (nc *NotificationController) MarkNotificationRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var notification models.Notification
+	if err := nc.DB.First(&notification, "id = ? AND user_id = ?", c.Params("id"), userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Notification not found"})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := nc.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update notification"})
+		}
+		notification.ReadAt = &now
+	}
+
+	return c.JSON(fiber.Map{"data": notificationResponse(&notification)})
+}
+
+// MarkAllNotificationsRead marks the whole inbox as read
+// POST /api/notifications/read-all
+func (nc *NotificationController) MarkAllNotificationsRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	result := nc.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update notifications"})
+	}
+
+	return c.JSON(fiber.Map{"message": "All notifications marked as read", "updated": result.RowsAffected})
+}
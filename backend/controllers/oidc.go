@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateType   = "oidc_state" // typ claim of the state token
+)
+
+type OIDCController struct {
+	DB   *gorm.DB
+	OIDC *services.OIDCService
+}
+
+func NewOIDCController(db *gorm.DB, oidc *services.OIDCService) *OIDCController {
+	return &OIDCController{DB: db, OIDC: oidc}
+}
+
+func frontendURL() string {
+	if u := os.Getenv("FRONTEND_URL"); u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// Login starts the authorization code + PKCE flow
+func (oc *OIDCController) Login(c *fiber.Ctx) error {
+	return oc.startFlow(c, "")
+}
+
+// Link starts the same flow for a logged-in user to attach an external identity
+func (oc *OIDCController) Link(c *fiber.Ctx) error {
+	return oc.startFlow(c, c.Locals("user_id").(string))
+}
+
+func (oc *OIDCController) startFlow(c *fiber.Ctx, linkUserID string) error {
+	provider, err := oc.OIDC.Provider(c.Params("provider"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown login provider"})
+	}
+
+	state, err := services.RandomURLSafe(24)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start login"})
+	}
+	nonce, err := services.RandomURLSafe(24)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start login"})
+	}
+	verifier, challenge, err := services.NewPKCE()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start login"})
+	}
+
+	authURL, err := oc.OIDC.AuthorizationURL(provider, state, nonce, challenge)
+	if err != nil {
+		fmt.Println("OIDC discovery error:", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Login provider unavailable"})
+	}
+
+	// Flow state lives in a signed, short-lived cookie instead of a table
+	stateToken, err := utils.GenerateSignedClaims(oidcStateType, jwt.MapClaims{
+		"provider":      provider.Name,
+		"state":         state,
+		"nonce":         nonce,
+		"code_verifier": verifier,
+		"link_user_id":  linkUserID,
+	}, 10*time.Minute)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start login"})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    stateToken,
+		Expires:  time.Now().Add(10 * time.Minute),
+		HTTPOnly: true,
+		Secure:   false, // Set to true in production
+		SameSite: "Lax",
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// Callback completes the flow: it links the identity (link mode) or logs the
+// user in, creating the account or linking by verified email when needed
+func (oc *OIDCController) Callback(c *fiber.Ctx) error {
+	provider, err := oc.OIDC.Provider(c.Params("provider"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown login provider"})
+	}
+
+	stateToken := c.Cookies(oidcStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+
+	if errParam := c.Query("error"); errParam != "" {
+		return oc.redirectWithError(c, errParam)
+	}
+
+	claims, err := utils.ParseSignedClaims(stateToken, oidcStateType)
+	if err != nil {
+		return oc.redirectWithError(c, "login_expired")
+	}
+	if claims["provider"] != provider.Name || claims["state"] != c.Query("state") {
+		return oc.redirectWithError(c, "invalid_state")
+	}
+
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["code_verifier"].(string)
+	linkUserID, _ := claims["link_user_id"].(string)
+
+	identity, err := oc.OIDC.Exchange(provider, c.Query("code"), verifier, nonce)
+	if err != nil {
+		fmt.Println("OIDC exchange error:", err)
+		return oc.redirectWithError(c, "provider_error")
+	}
+
+	if linkUserID != "" {
+		if err := oc.linkIdentity(linkUserID, provider.Name, identity); err != nil {
+			return oc.redirectWithError(c, err.Error())
+		}
+		return c.Redirect(frontendURL()+"/profile?linked="+url.QueryEscape(provider.Name), fiber.StatusFound)
+	}
+
+	user, err := oc.findOrCreateUser(provider.Name, identity)
+	if err != nil {
+		return oc.redirectWithError(c, err.Error())
+	}
+
+	sessionToken, err := utils.GenerateToken(user.ID, user.Role)
+	if err != nil {
+		return oc.redirectWithError(c, "login_failed")
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    sessionToken,
+		Expires:  time.Now().Add(time.Hour * 72),
+		HTTPOnly: true,
+		Secure:   false, // Set to true in production
+		SameSite: "Lax",
+	})
+
+	return c.Redirect(frontendURL()+"/", fiber.StatusFound)
+}
+
+func (oc *OIDCController) redirectWithError(c *fiber.Ctx, code string) error {
+	return c.Redirect(frontendURL()+"/login?error="+url.QueryEscape(code), fiber.StatusFound)
+}
+
+func (oc *OIDCController) linkIdentity(userID, provider string, identity *services.OIDCIdentity) error {
+	var existing models.UserIdentity
+	err := oc.DB.Where("provider = ? AND subject = ?", provider, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID == userID {
+			return nil
+		}
+		return errors.New("identity_already_linked")
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("link_failed")
+	}
+
+	link := models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	if err := oc.DB.Create(&link).Error; err != nil {
+		return errors.New("link_failed")
+	}
+	return nil
+}
+
+func (oc *OIDCController) findOrCreateUser(provider string, identity *services.OIDCIdentity) (*models.User, error) {
+	// 1. Already linked
+	var link models.UserIdentity
+	if err := oc.DB.Preload("User").Where("provider = ? AND subject = ?", provider, identity.Subject).First(&link).Error; err == nil {
+		return &link.User, nil
+	}
+
+	// Only a verified email may be used to match or create an account
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errors.New("email_not_verified")
+	}
+
+	tx := oc.DB.Begin()
+
+	// 2. Existing local account with the same email
+	var user models.User
+	err := tx.Where("LOWER(email) = LOWER(?) AND anonymized_at IS NULL", identity.Email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 3. New account without a usable password
+		hash, err := utils.UnusablePasswordHash()
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.New("login_failed")
+		}
+		now := time.Now()
+		user = models.User{
+			Name:          identity.Name,
+			Email:         identity.Email,
+			PasswordHash:  hash,
+			Role:          "user",
+			EmailVerified: &now,
+		}
+		if identity.Picture != "" {
+			user.Image = &identity.Picture
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("login_failed")
+		}
+	} else if err != nil {
+		tx.Rollback()
+		return nil, errors.New("login_failed")
+	}
+
+	link = models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	if err := tx.Create(&link).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("login_failed")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("login_failed")
+	}
+	return &user, nil
+}
+
+// GetIdentities lists external identities linked to the logged-in user
+func (oc *OIDCController) GetIdentities(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var identities []models.UserIdentity
+	if err := oc.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch identities"})
+	}
+
+	providers := []string{}
+	for name := range oc.OIDC.Providers {
+		providers = append(providers, name)
+	}
+
+	return c.JSON(fiber.Map{"data": identities, "available_providers": providers})
+}
+
+// UnlinkIdentity removes an external identity unless it is the user's only way to log in
+func (oc *OIDCController) UnlinkIdentity(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	id := c.Params("id")
+
+	var identity models.UserIdentity
+	if err := oc.DB.First(&identity, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Identity not found"})
+	}
+
+	var user models.User
+	if err := oc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	var identityCount int64
+	oc.DB.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&identityCount)
+	if identityCount <= 1 && utils.IsUnusablePassword(user.PasswordHash) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Cannot unlink your only login method"})
+	}
+
+	if err := oc.DB.Delete(&identity).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to unlink identity"})
+	}
+
+	services.RecordAudit(oc.DB, services.AuditEntry{
+		UserID:    userID,
+		Action:    "identity_unlinked",
+		TableName: "user_identities",
+		RecordID:  identity.ID,
+		OldData:   identity,
+		IPAddress: c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"message": "Identity unlinked"})
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	phoneOTPDigits      = 6
+	phoneOTPTTL         = 10 * time.Minute
+	phoneOTPResendAfter = time.Minute
+	phoneOTPMaxAttempts = 5
+)
+
+type RequestPhoneVerificationInput struct {
+	Phone string `json:"phone" validate:"required,min=8,max=20"`
+}
+
+type VerifyPhoneInput struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+func phoneCodeHash(userID, phone, code string) string {
+	return utils.HashAPIKey(userID + ":" + phone + ":" + code)
+}
+
+// RequestPhoneVerification sends a one-time code to the given number over WhatsApp
+// POST /api/profile/phone
+func (pc *ProfileController) RequestPhoneVerification(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input RequestPhoneVerificationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	phone, err := utils.NormalizePhone(input.Phone)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var last models.PhoneVerification
+	if err := pc.DB.Where("user_id = ?", userID).Order("created_at DESC").First(&last).Error; err == nil &&
+		time.Since(last.CreatedAt) < phoneOTPResendAfter {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Please wait before requesting another code"})
+	}
+
+	code, err := utils.GenerateOTP(phoneOTPDigits)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate code"})
+	}
+
+	verification := models.PhoneVerification{
+		UserID:    userID,
+		Phone:     phone,
+		CodeHash:  phoneCodeHash(userID, phone, code),
+		ExpiresAt: time.Now().Add(phoneOTPTTL),
+	}
+	if err := pc.DB.Create(&verification).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start verification"})
+	}
+
+	text := fmt.Sprintf("Kode verifikasi Diro Pilates Anda: %s\nBerlaku %d menit. Jangan bagikan kode ini.", code, int(phoneOTPTTL.Minutes()))
+	if err := pc.Messaging.SendMessage(context.Background(), phone, text); err != nil {
+		log.Printf("Sending OTP to user %s failed: %v", userID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Could not send code"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Verification code sent", "phone": phone, "expires_at": verification.ExpiresAt})
+}
+
+// VerifyPhone checks the code and stores the number as the user's verified phone
+// POST /api/profile/phone/verify
+func (pc *ProfileController) VerifyPhone(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input VerifyPhoneInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	var verification models.PhoneVerification
+	if err := pc.DB.Where("user_id = ? AND verified_at IS NULL", userID).
+		Order("created_at DESC").
+		First(&verification).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No pending verification"})
+	}
+
+	if time.Now().After(verification.ExpiresAt) {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "Code expired, request a new one"})
+	}
+
+	// Count the attempt atomically before comparing, so parallel guesses
+	// cannot get past phoneOTPMaxAttempts
+	result := pc.DB.Model(&models.PhoneVerification{}).
+		Where("id = ? AND attempts < ?", verification.ID, phoneOTPMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify phone"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "Code expired, request a new one"})
+	}
+
+	expected := phoneCodeHash(userID, verification.Phone, input.Code)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(verification.CodeHash)) != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Incorrect code"})
+	}
+
+	now := time.Now()
+	tx := pc.DB.Begin()
+	verified := tx.Model(&models.PhoneVerification{}).
+		Where("id = ? AND verified_at IS NULL", verification.ID).
+		Update("verified_at", now)
+	if verified.Error != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify phone"})
+	}
+	if verified.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Code already used"})
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"phone":             verification.Phone,
+		"phone_verified_at": now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify phone"})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify phone"})
+	}
+
+	services.RecordAudit(pc.DB, services.AuditEntry{
+		UserID:    userID,
+		Action:    "phone_verified",
+		TableName: "users",
+		RecordID:  userID,
+		IPAddress: c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"message": "Phone verified", "phone": verification.Phone})
+}
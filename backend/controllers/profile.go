@@ -0,0 +1,397 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+type ProfileController struct {
+	DB        *gorm.DB
+	Messaging services.MessagingProvider
+}
+
+func NewProfileController(db *gorm.DB, messaging services.MessagingProvider) *ProfileController {
+	return &ProfileController{DB: db, Messaging: messaging}
+}
+
+type UpdateProfileInput struct {
+	Name                  *string `json:"name" validate:"omitempty,min=3"`
+	Image                 *string `json:"image" validate:"omitempty,url"`
+	Phone                 *string `json:"phone" validate:"omitempty,min=8,max=20"`
+	EmergencyContactName  *string `json:"emergency_contact_name" validate:"omitempty,min=3"`
+	EmergencyContactPhone *string `json:"emergency_contact_phone" validate:"omitempty,min=8,max=20"`
+	Locale                *string `json:"locale" validate:"omitempty,oneof=id en"`
+}
+
+// reauthWindow is how recent the login must be for accounts without a
+// password (OIDC-only) to change their password or delete the account
+const reauthWindow = 10 * time.Minute
+
+// CurrentPassword / Password may be omitted by accounts that have no password yet
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,nefield=CurrentPassword"`
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password"`
+}
+
+func profileResponse(user *models.User) fiber.Map {
+	return fiber.Map{
+		"id":                      user.ID,
+		"name":                    user.Name,
+		"email":                   user.Email,
+		"role":                    user.Role,
+		"image":                   user.Image,
+		"phone":                   user.Phone,
+		"phone_verified_at":       user.PhoneVerifiedAt,
+		"emergency_contact_name":  user.EmergencyContactName,
+		"emergency_contact_phone": user.EmergencyContactPhone,
+		"locale":                  user.Locale,
+		"email_verified":          user.EmailVerified,
+		"created_at":              user.CreatedAt,
+	}
+}
+
+// GetProfile returns the full editable profile of the logged-in user
+func (pc *ProfileController) GetProfile(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var user models.User
+	if err := pc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	return c.JSON(fiber.Map{"user": profileResponse(&user)})
+}
+
+// UpdateProfile edits name, image, phone and emergency contact. Omitted fields are left unchanged.
+func (pc *ProfileController) UpdateProfile(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input UpdateProfileInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	var user models.User
+	if err := pc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	// Only the names of changed fields are audited; phone numbers and
+	// emergency contacts must not be copied into audit_logs
+	changed := []string{}
+	if input.Name != nil {
+		user.Name = *input.Name
+		changed = append(changed, "name")
+	}
+	if input.Image != nil {
+		user.Image = input.Image
+		changed = append(changed, "image")
+	}
+	if input.Phone != nil && (user.Phone == nil || *user.Phone != *input.Phone) {
+		// A new number has to be verified again before messages go to it
+		user.Phone = input.Phone
+		user.PhoneVerifiedAt = nil
+		changed = append(changed, "phone")
+	}
+	if input.EmergencyContactName != nil {
+		user.EmergencyContactName = input.EmergencyContactName
+		changed = append(changed, "emergency_contact_name")
+	}
+	if input.EmergencyContactPhone != nil {
+		user.EmergencyContactPhone = input.EmergencyContactPhone
+		changed = append(changed, "emergency_contact_phone")
+	}
+	if input.Locale != nil {
+		user.Locale = *input.Locale
+		changed = append(changed, "locale")
+	}
+
+	if err := pc.DB.Save(&user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update profile"})
+	}
+
+	impersonatorID, _ := c.Locals("impersonator_id").(string)
+	services.RecordAudit(pc.DB, services.AuditEntry{
+		UserID:         user.ID,
+		ImpersonatorID: impersonatorID,
+		Action:         "profile_updated",
+		TableName:      "users",
+		RecordID:       user.ID,
+		NewData:        fiber.Map{"changed_fields": changed},
+		IPAddress:      c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"message": "Profile updated", "user": profileResponse(&user)})
+}
+
+// ChangePassword requires the current password before setting a new one
+func (pc *ProfileController) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input ChangePasswordInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	var user models.User
+	if err := pc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if utils.IsUnusablePassword(user.PasswordHash) {
+		if !recentlyAuthenticated(c) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Log in again to set a password"})
+		}
+	} else if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.CurrentPassword)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Current password is incorrect"})
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+	}
+
+	if err := pc.DB.Model(&user).Update("password_hash", string(hash)).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to change password"})
+	}
+
+	services.RecordAudit(pc.DB, services.AuditEntry{
+		UserID:    user.ID,
+		Action:    "password_changed",
+		TableName: "users",
+		RecordID:  user.ID,
+		IPAddress: c.IP(),
+	})
+
+	return c.JSON(fiber.Map{"message": "Password changed"})
+}
+
+// recentlyAuthenticated reports whether the session token was issued by a login
+// within reauthWindow. Impersonation tokens and API keys never qualify.
+func recentlyAuthenticated(c *fiber.Ctx) bool {
+	issuedAt, ok := c.Locals("issued_at").(int64)
+	return ok && time.Since(time.Unix(issuedAt, 0)) <= reauthWindow
+}
+
+// ExportMyData returns every personal record we hold for the user as a JSON download
+func (pc *ProfileController) ExportMyData(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var user models.User
+	if err := pc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	var reservations []models.Reservation
+	if err := pc.DB.Preload("Court").Preload("Schedule").
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&reservations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not export reservations"})
+	}
+
+	var payments []models.Payment
+	if err := pc.DB.
+		Joins("JOIN reservations ON reservations.id = payments.reservation_id").
+		Where("reservations.user_id = ?", userID).
+		Order("payments.created_at ASC").
+		Find(&payments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not export payments"})
+	}
+
+	exportedPayments := []fiber.Map{}
+	for _, p := range payments {
+		exportedPayments = append(exportedPayments, fiber.Map{
+			"id":               p.ID,
+			"reservation_id":   p.ReservationID,
+			"order_id":         p.MidtransOrderID,
+			"amount":           p.Amount,
+			"status":           p.Status,
+			"payment_method":   p.PaymentMethod,
+			"transaction_time": p.TransactionTime,
+			"created_at":       p.CreatedAt,
+		})
+	}
+
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="my-data-%s.json"`, time.Now().Format("20060102")))
+
+	return c.JSON(fiber.Map{
+		"exported_at":  time.Now(),
+		"profile":      profileResponse(&user),
+		"reservations": reservations,
+		"payments":     exportedPayments,
+	})
+}
+
+// DeleteAccount anonymises the user's personal data. Reservations and payments are kept
+// (linked to the anonymised row) so financial records stay intact.
+func (pc *ProfileController) DeleteAccount(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input DeleteAccountInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	var user models.User
+	if err := pc.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if user.Role == "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admin accounts cannot be self-deleted"})
+	}
+
+	if utils.IsUnusablePassword(user.PasswordHash) {
+		if !recentlyAuthenticated(c) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Log in again to delete your account"})
+		}
+	} else if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password is incorrect"})
+	}
+
+	// Unusable hash so the account can never log in again
+	unusableHash, err := utils.UnusablePasswordHash()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete account"})
+	}
+
+	tx := domain.WithActor(pc.DB.Begin(), actorFromContext(c, domain.SourceAccountDeletion))
+
+	// Release seats held by unpaid reservations
+	var pending []models.Reservation
+	tx.Where("user_id = ? AND status = ?", user.ID, domain.ReservationPending).Find(&pending)
+	for i := range pending {
+		if err := domain.TransitionReservation(tx, &pending[i], domain.ReservationCancelled); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel pending reservations"})
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"name":                    "Deleted User",
+		"email":                   fmt.Sprintf("deleted-%s@deleted.invalid", user.ID),
+		"password_hash":           unusableHash,
+		"image":                   nil,
+		"phone":                   nil,
+		"phone_verified_at":       nil,
+		"calendar_token_hash":     nil,
+		"emergency_contact_name":  nil,
+		"emergency_contact_phone": nil,
+		"email_verified":          nil,
+		"anonymized_at":           now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// Personal permissions and social logins are meaningless once the account is gone
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserPermission{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserIdentity{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// Guests named on this account's bookings, and seats it claimed elsewhere
+	if err := tx.Model(&models.ReservationAttendee{}).
+		Where("reservation_id IN (?) OR user_id = ?", tx.Model(&models.Reservation{}).Select("id").Where("user_id = ?", user.ID), user.ID).
+		Updates(map[string]interface{}{
+			"name":                   "Deleted User",
+			"email":                  "deleted@deleted.invalid",
+			"phone":                  nil,
+			"user_id":                nil,
+			"claim_token_hash":       "",
+			"claim_token_expires_at": nil,
+		}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// Integrations acting as this account stop working with it
+	if err := tx.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Update("revoked_at", now).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// The delivery log keeps what was sent when, not to whom or what it said
+	if err := tx.Model(&models.NotificationDelivery{}).
+		Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"recipient": "", "subject": "", "body": ""}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// Every number the account asked to verify
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PhoneVerification{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// Nobody can read the inbox any more
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	// Older audit entries may still carry profile snapshots and IP addresses;
+	// the actions themselves are kept
+	if err := tx.Model(&models.AuditLog{}).
+		Where("user_id = ? OR (table_name = ? AND record_id = ?)", user.ID, "users", user.ID).
+		Updates(map[string]interface{}{"old_data": nil, "new_data": nil, "ip_address": ""}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
+	}
+
+	services.RecordAudit(pc.DB, services.AuditEntry{
+		UserID:    user.ID,
+		Action:    "account_deleted",
+		TableName: "users",
+		RecordID:  user.ID,
+	})
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+
+	return c.JSON(fiber.Map{"message": "Account deleted"})
+}
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	defaultRescheduleCutoffHours = 12
+	defaultMaxReschedules        = 2
+)
+
+type RescheduleInput struct {
+	ScheduleID string `json:"schedule_id" validate:"required,uuid"`
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+// RescheduleReservation moves a paid booking to another slot.
+// POST /api/reservations/:id/reschedule
+func (rc *ReservationController) RescheduleReservation(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := c.Locals("user_id").(string)
+
+	var input RescheduleInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	cutoff := time.Duration(envInt("RESCHEDULE_CUTOFF_HOURS", defaultRescheduleCutoffHours)) * time.Hour
+
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceUser))
+
+	var reservation models.Reservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", id).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
+	}
+
+	if reservation.UserID != userID {
+		tx.Rollback()
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized"})
+	}
+
+	var moves int64
+	if err := tx.Model(&models.ReservationReschedule{}).Where("reservation_id = ? AND reverted_at IS NULL", reservation.ID).Count(&moves).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reschedule reservation"})
+	}
+	if int(moves) >= envInt("RESCHEDULE_MAX_PER_RESERVATION", defaultMaxReschedules) {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Reservation has been rescheduled too many times"})
+	}
+
+	// 1. Lock both schedule rows in a deterministic order to avoid deadlocks
+	lockOrder := []string{reservation.ScheduleID, input.ScheduleID}
+	sort.Strings(lockOrder)
+	locked := map[string]*models.Schedule{}
+	for _, scheduleID := range lockOrder {
+		var s models.Schedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Court").First(&s, "id = ?", scheduleID).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+		}
+		locked[scheduleID] = &s
+	}
+	fromSchedule := locked[reservation.ScheduleID]
+	toSchedule := locked[input.ScheduleID]
+
+	// 2. Policy cutoffs
+	now := time.Now()
+	if domain.ScheduleStart(fromSchedule).Sub(now) < cutoff {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Reservations can only be rescheduled up to %d hours before the session", int(cutoff.Hours())),
+		})
+	}
+	if !domain.ScheduleStart(toSchedule).After(now) {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Target schedule has already started"})
+	}
+
+	// 3. Move and adjust seats
+	oldAmount := reservation.TotalAmount
+	if err := domain.MoveReservation(tx, &reservation, toSchedule); err != nil {
+		tx.Rollback()
+		return transitionErrorResponse(c, err, "Failed to reschedule reservation")
+	}
+	difference := reservation.TotalAmount - oldAmount
+
+	reschedule := models.ReservationReschedule{
+		ReservationID:   reservation.ID,
+		FromScheduleID:  fromSchedule.ID,
+		ToScheduleID:    toSchedule.ID,
+		PriceDifference: difference,
+		ActorID:         userID,
+	}
+
+	var bookingPayment models.Payment
+	tx.Where("reservation_id = ? AND kind = ?", reservation.ID, models.PaymentKindBooking).First(&bookingPayment)
+
+	response := fiber.Map{
+		"message":          "Reservation rescheduled",
+		"reservation_id":   reservation.ID,
+		"schedule_id":      reservation.ScheduleID,
+		"price_difference": difference,
+	}
+
+	// 4. Charge the difference with its own Snap transaction
+	if difference > 0 {
+		var user models.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "User data error"})
+		}
+
+		// Reverted moves are not counted against the limit but keep their order IDs
+		var allMoves int64
+		if err := tx.Model(&models.ReservationReschedule{}).Where("reservation_id = ?", reservation.ID).Count(&allMoves).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reschedule reservation"})
+		}
+
+		orderID := fmt.Sprintf("%s-RS%d", reservation.ID, allMoves+1)
+		token, redirectURL, err := rc.Midtrans.GenerateAdjustmentSnapToken(orderID, difference, "Reschedule Difference", &reservation, &user)
+		if err != nil {
+			tx.Rollback()
+			log.Println("Midtrans Error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate payment token"})
+		}
+
+		adjustment := models.Payment{
+			ReservationID:   reservation.ID,
+			MidtransOrderID: orderID,
+			Amount:          difference,
+			Kind:            models.PaymentKindAdjustment,
+			Status:          domain.PaymentPending,
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to init payment"})
+		}
+
+		reschedule.AdjustmentPaymentID = &adjustment.ID
+		response["message"] = "Reservation rescheduled; the move is undone if the difference is not paid"
+		response["snap_token"] = token
+		response["redirect_url"] = redirectURL
+	} else if difference < 0 {
+		reschedule.RefundStatus = models.RescheduleRefundRequired
+	}
+
+	if err := tx.Create(&reschedule).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record reschedule"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reschedule reservation"})
+	}
+
+	// 5. Refund the difference after commit so a failed refund never blocks the move.
+	// Failed refunds are listed for admins, who can retry them.
+	if difference < 0 {
+		refundTx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceUser))
+		status, err := rc.refundRescheduleDifference(refundTx, &bookingPayment, &reschedule, -difference)
+		if err == nil {
+			err = refundTx.Commit().Error
+		} else {
+			refundTx.Rollback()
+		}
+		if err != nil {
+			log.Printf("Recording refund for reschedule %s failed: %v", reschedule.ID, err)
+			status = models.RescheduleRefundRequired
+		}
+		response["refund_status"] = status
+	}
+
+	return c.JSON(response)
+}
+
+// refundRescheduleDifference refunds amount through Midtrans when the booking was
+// paid online and records the outcome on the reschedule inside tx. Cash bookings
+// are flagged for a refund at the front desk. Midtrans deduplicates refunds by
+// the reschedule ID, so retrying after a failure cannot refund twice.
+func (rc *ReservationController) refundRescheduleDifference(tx *gorm.DB, payment *models.Payment, reschedule *models.ReservationReschedule, amount float64) (string, error) {
+	status := models.RescheduleRefundDone
+
+	if payment.ID == "" || payment.PaymentMethod == "manual_cash" {
+		status = models.RescheduleRefundManual
+	} else if err := rc.Midtrans.RefundTransaction(payment.OrderID(), amount, reschedule.ID, "Reschedule to a cheaper slot"); err != nil {
+		log.Printf("Refund for reschedule %s failed: %v", reschedule.ID, err)
+		status = models.RescheduleRefundFailed
+	}
+
+	if status == models.RescheduleRefundDone {
+		if err := domain.RecordPartialRefund(tx, payment, amount); err != nil {
+			return "", err
+		}
+	}
+	if err := tx.Model(reschedule).Update("refund_status", status).Error; err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+// GetReschedules lists the moves of a reservation
+func (rc *ReservationController) GetReschedules(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := c.Locals("user_id").(string)
+
+	var reservation models.Reservation
+	if err := rc.DB.First(&reservation, "id = ?", id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
+	}
+
+	if reservation.UserID != userID && c.Locals("role") != "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized"})
+	}
+
+	var reschedules []models.ReservationReschedule
+	if err := rc.DB.Where("reservation_id = ?", id).Order("created_at ASC").Find(&reschedules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch reschedules"})
+	}
+
+	return c.JSON(fiber.Map{"data": reschedules})
+}
+
+// rescheduleRefundStale is how long a refund may stay "required" before it is
+// assumed that the request recording it died and an admin has to retry it
+const rescheduleRefundStale = 10 * time.Minute
+
+// AdminGetReschedulesForReview lists moves that need an admin: top-ups that
+// could not be undone and refunds that failed or were never recorded
+// GET /api/admin/reschedules/review
+func (rc *ReservationController) AdminGetReschedulesForReview(c *fiber.Ctx) error {
+	var reschedules []models.ReservationReschedule
+	if err := rc.DB.
+		Where("needs_review_at IS NOT NULL AND reviewed_at IS NULL").
+		Or("refund_status = ?", models.RescheduleRefundFailed).
+		Or("refund_status = ? AND created_at < ?", models.RescheduleRefundRequired, time.Now().Add(-rescheduleRefundStale)).
+		Order("created_at ASC").
+		Find(&reschedules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch reschedules"})
+	}
+
+	return c.JSON(fiber.Map{"data": reschedules})
+}
+
+// AdminRetryRescheduleRefund refunds the difference of a move to a cheaper
+// slot again after the first attempt failed or was never recorded
+// POST /api/admin/reschedules/:id/retry-refund
+func (rc *ReservationController) AdminRetryRescheduleRefund(c *fiber.Ctx) error {
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceAdmin))
+
+	// The row lock keeps two admins from refunding the same move at once
+	var reschedule models.ReservationReschedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reschedule, "id = ?", c.Params("id")).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reschedule not found"})
+	}
+
+	if reschedule.RefundStatus != models.RescheduleRefundFailed && reschedule.RefundStatus != models.RescheduleRefundRequired {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "No refund is outstanding for this reschedule"})
+	}
+
+	var bookingPayment models.Payment
+	if err := tx.Where("reservation_id = ? AND kind = ?", reschedule.ReservationID, models.PaymentKindBooking).
+		First(&bookingPayment).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load payment"})
+	}
+
+	status, err := rc.refundRescheduleDifference(tx, &bookingPayment, &reschedule, -reschedule.PriceDifference)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record refund"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record refund"})
+	}
+
+	if status == models.RescheduleRefundFailed {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Refund failed again", "refund_status": status})
+	}
+
+	return c.JSON(fiber.Map{"message": "Refund processed", "refund_status": status})
+}
+
+// AdminResolveReschedule marks a flagged move as handled
+// POST /api/admin/reschedules/:id/resolve
+func (rc *ReservationController) AdminResolveReschedule(c *fiber.Ctx) error {
+	result := rc.DB.Model(&models.ReservationReschedule{}).
+		Where("id = ? AND needs_review_at IS NOT NULL AND reviewed_at IS NULL", c.Params("id")).
+		Update("reviewed_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resolve reschedule"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No open review for this reschedule"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Reschedule marked as reviewed"})
+}
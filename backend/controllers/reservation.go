@@ -1,19 +1,24 @@
 package controllers
 
 import (
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
 	"github.com/Giriathallah/diro-pilates-backend/models"
 	"github.com/Giriathallah/diro-pilates-backend/services"
 	"github.com/Giriathallah/diro-pilates-backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ReservationController struct {
@@ -38,41 +43,39 @@ func (rc *ReservationController) MarkReservationAsPaid(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found or unauthorized"})
 	}
 
-	if reservation.Status != "pending" {
+	if reservation.Status != domain.ReservationPending {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reservation is not pending"})
 	}
 
-	// Verify with Midtrans (Best Practice even for manual trigger)
-	txResp, err := rc.Midtrans.VerifyTransaction(reservation.ID)
+	// The customer's word is not enough: Midtrans must confirm the payment
+	txResp, err := rc.Midtrans.VerifyTransaction(orderIDFor(&reservation))
 	if err != nil {
 		fmt.Println("VerifyTransaction error on mark-paid:", err)
-		// For local dev/test without public webhooks, we might want to proceed if we trust the frontend 'onSuccess'
-	} else if txResp != nil {
-		if txResp.TransactionStatus != "capture" && txResp.TransactionStatus != "settlement" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Payment not yet successful according to Midtrans"})
-		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Could not verify payment with Midtrans"})
+	}
+	if services.PaymentStatusFromMidtrans(txResp.TransactionStatus, txResp.FraudStatus) != domain.PaymentSuccess {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Payment not yet successful according to Midtrans"})
 	}
 
-	tx := rc.DB.Begin()
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceMarkPaid))
 
-	// Update Payment
-	var payment models.Payment
-	if err := tx.Where("reservation_id = ?", reservation.ID).First(&payment).Error; err == nil {
-		payment.Status = "success"
-		payment.PaymentMethod = "manual_sync_frontend"
-		if txResp != nil {
-			payment.PaymentMethod = txResp.PaymentType
-		}
-		currentTime := time.Now()
-		payment.TransactionTime = &currentTime
-		tx.Save(&payment)
+	// Update Payments (find or create); the domain moves the reservations to paid
+	payments, err := bookingPaymentsFor(tx, &reservation)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update payment"})
 	}
 
-	// Update Reservation
-	reservation.Status = "paid"
-	if err := tx.Save(&reservation).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update reservation"})
+	currentTime := time.Now()
+	for i := range payments {
+		payment := &payments[i]
+		payment.PaymentMethod = txResp.PaymentType
+		payment.TransactionTime = &currentTime
+
+		if err := domain.ApplyPaymentStatus(tx, payment, domain.PaymentSuccess); err != nil {
+			tx.Rollback()
+			return transitionErrorResponse(c, err, "Failed to update reservation")
+		}
 	}
 
 	tx.Commit()
@@ -88,7 +91,7 @@ func (rc *ReservationController) MarkReservationAsPaid(c *fiber.Ctx) error {
 // GetAvailableDates returns list of dates that have available schedules
 func (rc *ReservationController) GetAvailableDates(c *fiber.Ctx) error {
 	var dates []time.Time
-	today := time.Now().Truncate(24 * time.Hour)
+	today := utils.StudioNow().Format("2006-01-02")
 
 	if err := rc.DB.Model(&models.Schedule{}).
 		Where("is_available = ? AND date >= ?", true, today).
@@ -106,6 +109,16 @@ func (rc *ReservationController) GetAvailableDates(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"dates": availableDates})
 }
 
+// upcomingOnly hides today's sessions that have already started. date is
+// the requested YYYY-MM-DD, compared with today in the studio timezone.
+func upcomingOnly(db *gorm.DB, date string) *gorm.DB {
+	now := utils.StudioNow()
+	if date != now.Format("2006-01-02") {
+		return db
+	}
+	return db.Where("start_time > ?", models.NewTimeOfDay(now.Hour(), now.Minute()))
+}
+
 // GetTimeSlots returns available time slots for a specific date
 func (rc *ReservationController) GetTimeSlots(c *fiber.Ctx) error {
 	dateParam := c.Query("date")
@@ -114,8 +127,8 @@ func (rc *ReservationController) GetTimeSlots(c *fiber.Ctx) error {
 	}
 
 	// Cari unique start_time pada tanggal tersebut yang available
-	timeSlots := []string{}
-	if err := rc.DB.Model(&models.Schedule{}).
+	timeSlots := []models.TimeOfDay{}
+	if err := upcomingOnly(rc.DB, dateParam).Model(&models.Schedule{}).
 		Where("date = ? AND is_available = ?", dateParam, true).
 		Distinct("start_time").
 		Order("start_time ASC").
@@ -137,11 +150,15 @@ func (rc *ReservationController) GetAvailableCourts(c *fiber.Ctx) error {
 	if dateParam == "" || timeParam == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Date and Time parameters are required"})
 	}
+	startTime, err := models.ParseTimeOfDay(timeParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid time format. Use HH:MM"})
+	}
 
 	// Cari schedule yang match date & time & available, preload court
 	var schedules []models.Schedule
-	if err := rc.DB.Preload("Court").
-		Where("date = ? AND start_time = ? AND is_available = ?", dateParam, timeParam, true).
+	if err := upcomingOnly(rc.DB, dateParam).Preload("Court").
+		Where("date = ? AND start_time = ? AND is_available = ?", dateParam, startTime, true).
 		Find(&schedules).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch courts"})
 	}
@@ -152,10 +169,12 @@ func (rc *ReservationController) GetAvailableCourts(c *fiber.Ctx) error {
 			"schedule_id": s.ID,
 			"court_id":    s.Court.ID,
 			"court_name":  s.Court.Name,
-			"price":       s.Court.PricePerSlot,
-			"capacity":    s.Court.Capacity,
+			"price":       s.SlotPrice(s.Court),
+			"capacity":    s.SeatCapacity(s.Court),
 			"start_time":  s.StartTime,
 			"end_time":    s.EndTime,
+			"starts_at":   s.StartsAt(),
+			"ends_at":     s.EndsAt(),
 		})
 	}
 
@@ -191,7 +210,7 @@ func (rc *ReservationController) GetSchedules(c *fiber.Ctx) error {
 	// Let's stick to filtering available=true for now as per plan, unless user code suggests otherwise.
 	// User code: `{!loading && schedules.length === 0 ... "Tidak ada jadwal tersedia"}`
 	// Seems filtering available is safer to avoid showing booked slots user can't click.
-	if err := rc.DB.Preload("Court").Where("date = ? AND is_available = ?", dateStr, true).Find(&schedules).Error; err != nil {
+	if err := upcomingOnly(rc.DB, dateStr).Preload("Court").Where("date = ? AND is_available = ?", dateStr, true).Find(&schedules).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch schedules"})
 	}
 
@@ -220,7 +239,7 @@ func (rc *ReservationController) CreateReservation(c *fiber.Ctx) error {
 	}
 
 	// Start Transaction
-	tx := rc.DB.Begin()
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceUser))
 
 	// 1. Lock schedule row to prevent race condition
 	var schedule models.Schedule
@@ -242,22 +261,20 @@ func (rc *ReservationController) CreateReservation(c *fiber.Ctx) error {
 	}
 
 	// 3. Check Capacity
-	var currentBookings int64
-	if err := tx.Model(&models.Reservation{}).
-		Where("schedule_id = ? AND status IN ('pending', 'paid', 'confirmed')", schedule.ID).
-		Count(&currentBookings).Error; err != nil {
+	currentBookings, err := domain.BookedSeats(tx, schedule.ID)
+	if err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check capacity"})
 	}
 
 	// If fully booked, prevent reservation
-	if int(currentBookings) >= court.Capacity {
+	if currentBookings >= schedule.SeatCapacity(court) {
 		tx.Rollback()
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Class is fully booked"})
 	}
 
 	// 4. Update schedule availability if this booking fills the last slot
-	if int(currentBookings)+1 >= court.Capacity {
+	if currentBookings+1 >= schedule.SeatCapacity(court) {
 		if err := tx.Model(&schedule).Update("is_available", false).Error; err != nil {
 			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to lock schedule"})
@@ -276,8 +293,9 @@ func (rc *ReservationController) CreateReservation(c *fiber.Ctx) error {
 		UserID:      userID,
 		CourtID:     schedule.CourtID,
 		ScheduleID:  schedule.ID,
-		Status:      "pending",
-		TotalAmount: court.PricePerSlot,
+		Seats:       1,
+		Status:      domain.ReservationPending,
+		TotalAmount: schedule.SlotPrice(court),
 		Notes:       input.Notes,
 	}
 
@@ -286,6 +304,16 @@ func (rc *ReservationController) CreateReservation(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
 	}
 
+	if err := events.Publish(tx, events.ReservationCreated, reservation.ID, events.NewReservationPayload(&reservation, "")); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+	}
+
+	if err := domain.RecordReservationEvent(tx, reservation.ID, domain.TimelineCreated, "", reservation.Status, events.NewReservationPayload(&reservation, "")); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+	}
+
 	// 6. Generate Midtrans Snap Token
 	// Note: We use ReservationID as OrderID.
 	// Since we create a NEW reservation for every POST, ID is unique.
@@ -301,7 +329,7 @@ func (rc *ReservationController) CreateReservation(c *fiber.Ctx) error {
 		ReservationID:   reservation.ID,
 		MidtransOrderID: reservation.ID,
 		Amount:          reservation.TotalAmount,
-		Status:          "pending",
+		Status:          domain.PaymentPending,
 	}
 
 	if err := tx.Create(&payment).Error; err != nil {
@@ -334,105 +362,76 @@ func (rc *ReservationController) GetMyReservations(c *fiber.Ctx) error {
 	}
 
 	// Proactive Check for Pending Reservations
-	// Proactive Check for Pending Reservations
+	checkedOrders := map[string]bool{}
 	for i, res := range reservations {
-		if res.Status == "pending" {
-			fmt.Printf("Proactive check for pending reservation ID: %s\n", res.ID)
+		if res.Status == domain.ReservationPending {
+			orderID := orderIDFor(&res)
+			if checkedOrders[orderID] {
+				// Another reservation of the same cart already synced it
+				rc.DB.Select("status").First(&reservations[i], "id = ?", res.ID)
+				continue
+			}
+			checkedOrders[orderID] = true
 
-			resp, err := rc.Midtrans.VerifyTransaction(res.ID)
+			resp, err := rc.Midtrans.VerifyTransaction(orderID)
 			if err != nil {
 				fmt.Printf("VerifyTransaction failed for %s: %v\n", res.ID, err)
 				continue
 			}
 
 			if resp == nil {
-				fmt.Println("Midtrans response nil for", res.ID)
 				continue
 			}
 
-			fmt.Printf("Midtrans status for %s: %s | fraud: %s | payment_type: %s\n",
-				res.ID, resp.TransactionStatus, resp.FraudStatus, resp.PaymentType)
-
-			var newStatus string
-			if resp.TransactionStatus == "capture" || resp.TransactionStatus == "settlement" {
-				if resp.TransactionStatus == "capture" && resp.FraudStatus == "challenge" {
-					newStatus = "pending"
-				} else {
-					newStatus = "success" // This maps to "paid" for reservation
-				}
-			} else if resp.TransactionStatus == "deny" || resp.TransactionStatus == "expire" || resp.TransactionStatus == "cancel" {
-				newStatus = "failed"
+			newStatus := services.PaymentStatusFromMidtrans(resp.TransactionStatus, resp.FraudStatus)
+			if newStatus == "" || newStatus == domain.PaymentPending || newStatus == domain.PaymentPartiallyRefunded {
+				continue
 			}
 
-			if newStatus == "" {
-				fmt.Println("No actionable new status for", res.ID)
+			tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceProactiveCheck))
+
+			// Update Payments (find or create)
+			payments, err := bookingPaymentsFor(tx, &res)
+			if err != nil {
+				fmt.Println("Error finding payment:", err)
+				tx.Rollback()
 				continue
 			}
 
-			fmt.Printf("Updating status to %s for reservation %s\n", newStatus, res.ID)
-
-			tx := rc.DB.Begin()
-
-			// Update Payment (find or create)
-			var payment models.Payment
-			paymentFound := true
-			if err := tx.Where("reservation_id = ?", res.ID).First(&payment).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					fmt.Println("Payment record not found, creating new for", res.ID)
-					payment = models.Payment{
-						ReservationID:   res.ID,
-						MidtransOrderID: res.ID,
-						Amount:          res.TotalAmount,
-						Status:          newStatus,
+			paymentBytes, _ := json.Marshal(resp)
+			applied := true
+			for j := range payments {
+				payment := &payments[j]
+
+				// Update fields
+				if resp.PaymentType != "" {
+					payment.PaymentMethod = resp.PaymentType
+				}
+				if resp.TransactionTime != "" {
+					if t, err := time.Parse("2006-01-02 15:04:05", resp.TransactionTime); err == nil {
+						payment.TransactionTime = &t
 					}
-					paymentFound = false
-				} else {
-					fmt.Println("Error finding payment:", err)
-					tx.Rollback()
-					continue
 				}
-			}
+				payment.MidtransResponse = paymentBytes
 
-			// Update fields
-			payment.Status = newStatus
-			if resp.PaymentType != "" {
-				payment.PaymentMethod = resp.PaymentType
-			}
-			if resp.TransactionTime != "" {
-				if t, err := time.Parse("2006-01-02 15:04:05", resp.TransactionTime); err == nil {
-					payment.TransactionTime = &t
+				if err := domain.ApplyPaymentStatus(tx, payment, newStatus); err != nil {
+					fmt.Printf("Proactive check for %s: %v\n", res.ID, err)
+					applied = false
+					break
 				}
 			}
-			paymentBytes, _ := json.Marshal(resp)
-			payment.MidtransResponse = paymentBytes
-
-			if paymentFound {
-				tx.Save(&payment)
-			} else {
-				tx.Create(&payment)
-			}
-
-			// Update Reservation
-			if newStatus == "success" {
-				res.Status = "paid"
-				tx.Model(&models.Reservation{}).Where("id = ?", res.ID).Update("status", "paid")
-			} else if newStatus == "failed" {
-				res.Status = "cancelled"
-				tx.Model(&models.Reservation{}).Where("id = ?", res.ID).Update("status", "cancelled")
-
-				// Release schedule
-				tx.Model(&models.Schedule{}).Where("id = ?", res.ScheduleID).Update("is_available", true)
+			if !applied {
+				tx.Rollback()
+				continue
 			}
 
 			if err := tx.Commit().Error; err != nil {
 				fmt.Println("Transaction commit failed:", err)
-				tx.Rollback()
 				continue
 			}
 
 			// Update in-memory for response
-			reservations[i].Status = res.Status
-			fmt.Printf("Updated reservation %s to %s\n", res.ID, res.Status)
+			rc.DB.Select("status").First(&reservations[i], "id = ?", res.ID)
 		}
 	}
 
@@ -453,38 +452,41 @@ func (rc *ReservationController) CancelReservation(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized"})
 	}
 
-	if reservation.Status != "pending" {
+	if reservation.Status != domain.ReservationPending {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Only pending reservations can be cancelled"})
 	}
 
 	// Transaction to cancel and free up schedule
-	tx := rc.DB.Begin()
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceUser))
 
-	reservation.Status = "cancelled"
-	if err := tx.Save(&reservation).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel reservation"})
+	// An unpaid cart is paid in one transaction, so its reservations are cancelled together
+	toCancel := []models.Reservation{reservation}
+	if reservation.BookingID != nil {
+		toCancel = nil
+		tx.Where("booking_id = ? AND status = ?", *reservation.BookingID, domain.ReservationPending).
+			Order("id ASC").
+			Find(&toCancel)
 	}
 
-	if err := tx.Model(&models.Schedule{}).
-		Where("id = ?", reservation.ScheduleID).
-		Update("is_available", true).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to release schedule"})
+	for i := range toCancel {
+		if err := domain.TransitionReservation(tx, &toCancel[i], domain.ReservationCancelled); err != nil {
+			tx.Rollback()
+			return transitionErrorResponse(c, err, "Failed to cancel reservation")
+		}
 	}
 
 	tx.Commit()
 
-	return c.JSON(fiber.Map{"message": "Reservation cancelled"})
+	return c.JSON(fiber.Map{"message": "Reservation cancelled", "cancelled": len(toCancel)})
 }
 
 // Webhook Handler for Midtrans
+// Verified notifications are stored in the webhook inbox first, then processed.
+// Duplicates of an already processed notification are acknowledged without reprocessing.
 func (rc *ReservationController) HandleMidtransNotification(c *fiber.Ctx) error {
-	fmt.Println("WEBHOOK RECEIVED! Raw body:", string(c.Body()))
-
 	var notificationPayload map[string]interface{}
 	if err := c.BodyParser(&notificationPayload); err != nil {
-		fmt.Println("Body parse error:", err)
+		log.Println("Midtrans webhook: body parse error:", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid notification payload"})
 	}
 
@@ -495,13 +497,7 @@ func (rc *ReservationController) HandleMidtransNotification(c *fiber.Ctx) error
 
 	transactionStatus, _ := notificationPayload["transaction_status"].(string)
 	fraudStatus, _ := notificationPayload["fraud_status"].(string)
-	paymentTypeVal, _ := notificationPayload["payment_type"].(string)
-	signatureKeyVal, _ := notificationPayload["signature_key"].(string)
-
-	fmt.Printf("Webhook for OrderID: %s\n", orderId)
-	fmt.Printf("Transaction Status: %v\n", transactionStatus)
-	fmt.Printf("Payment Type: %v\n", paymentTypeVal)
-	fmt.Printf("Signature Key received: %v\n", signatureKeyVal)
+	transactionID, _ := notificationPayload["transaction_id"].(string)
 
 	// 1. Verify Signature Key for security
 	signatureKey, _ := notificationPayload["signature_key"].(string)
@@ -519,88 +515,47 @@ func (rc *ReservationController) HandleMidtransNotification(c *fiber.Ctx) error
 	expectedSignature := hex.EncodeToString(hashArg[:])
 
 	if signatureKey != expectedSignature {
-		fmt.Println("Invalid Signature!")
+		log.Printf("Midtrans webhook: invalid signature for order %s", orderId)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid signature"})
 	}
 
-	var payment models.Payment
-	if err := rc.DB.Where("midtrans_order_id = ?", orderId).First(&payment).Error; err != nil {
-		fmt.Println("Payment not found for order:", orderId)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
-	}
-
-	// Determine new status
-	var newStatus string
-	if transactionStatus == "capture" {
-		if fraudStatus == "challenge" {
-			newStatus = "pending"
-		} else if fraudStatus == "accept" {
-			newStatus = "success"
-		}
-	} else if transactionStatus == "settlement" {
-		newStatus = "success"
-	} else if transactionStatus == "deny" || transactionStatus == "expire" || transactionStatus == "cancel" {
-		newStatus = "failed"
-	} else if transactionStatus == "pending" {
-		newStatus = "pending"
-	}
-
-	// Extract additional fields
-	paymentType, _ := notificationPayload["payment_type"].(string)
-	transactionTimeStr, _ := notificationPayload["transaction_time"].(string)
-
-	var transactionTime *time.Time
-	if transactionTimeStr != "" {
-		if facebookTime, err := time.Parse("2006-01-02 15:04:05", transactionTimeStr); err == nil {
-			transactionTime = &facebookTime
-		}
+	// 2. Persist to inbox (deduplicated)
+	dedupHash := sha256.Sum256([]byte(orderId + "|" + transactionID + "|" + transactionStatus + "|" + fraudStatus + "|" + statusCode))
+	event := models.WebhookEvent{
+		Source:            "midtrans",
+		DedupKey:          hex.EncodeToString(dedupHash[:]),
+		OrderID:           orderId,
+		TransactionID:     transactionID,
+		TransactionStatus: transactionStatus,
+		FraudStatus:       fraudStatus,
+		Payload:           c.Body(),
+		Status:            models.WebhookReceived,
 	}
 
-	// Update if status changed OR if we just need to capture details (sometimes successful callbacks come fast)
-	// We should update details regardless if it's the final state
-	if newStatus != "" {
-		tx := rc.DB.Begin()
-
-		payment.Status = newStatus
-		payment.PaymentMethod = paymentType
-		payment.TransactionTime = transactionTime
-
-		paymentBytes, _ := json.Marshal(notificationPayload)
-		payment.MidtransResponse = paymentBytes
+	result := rc.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		log.Printf("Midtrans webhook: failed to store notification for order %s: %v", orderId, result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to store notification"})
+	}
 
-		if err := tx.Save(&payment).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update payment"})
+	if result.RowsAffected == 0 {
+		if err := rc.DB.Where("dedup_key = ?", event.DedupKey).First(&event).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load notification"})
 		}
-
-		// Update Reservation & Schedule
-		if newStatus == "success" {
-			if err := tx.Model(&models.Reservation{}).
-				Where("id = ?", payment.ReservationID).
-				Update("status", "paid").Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update reservation"})
-			}
-			// Schedule is already false, keep it false.
-		} else if newStatus == "failed" {
-			if err := tx.Model(&models.Reservation{}).
-				Where("id = ?", payment.ReservationID).
-				Update("status", "cancelled").Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update reservation"})
-			}
-			// Release schedule
-			var reservation models.Reservation
-			tx.First(&reservation, "id = ?", payment.ReservationID)
-			tx.Model(&models.Schedule{}).
-				Where("id = ?", reservation.ScheduleID).
-				Update("is_available", true) // Make available again
+		// Only failed (or interrupted) deliveries are retried
+		if webhookSettled(event.Status) {
+			return c.JSON(fiber.Map{"message": "Duplicate notification", "event_id": event.ID})
 		}
+	}
 
-		tx.Commit()
+	// 3. Process through the payment state machine
+	if err := rc.processWebhookEvent(&event, domain.Actor{Source: domain.SourceWebhook}, false); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process notification"})
 	}
 
-	// ... existing Webhook handler code ...
 	return c.SendStatus(fiber.StatusOK)
 }
 
@@ -636,7 +591,7 @@ func (rc *ReservationController) AdminCancelReservation(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	// Transaction similar to user cancel, but no user check
-	tx := rc.DB.Begin()
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceAdmin))
 
 	var reservation models.Reservation
 	if err := tx.First(&reservation, "id = ?", id).Error; err != nil {
@@ -644,24 +599,74 @@ func (rc *ReservationController) AdminCancelReservation(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
 	}
 
-	// Logic: if paid, maybe trigger refund (not implemented), just mark refunded/cancelled
-	// If pending, just cancel
+	// Logic: if paid, maybe trigger refund (not implemented), just mark cancelled
+	// If pending, just cancel. The domain releases the seat.
+	if err := domain.TransitionReservation(tx, &reservation, domain.ReservationCancelled); err != nil {
+		tx.Rollback()
+		return transitionErrorResponse(c, err, "Failed to cancel")
+	}
 
-	reservation.Status = "cancelled"
-	if err := tx.Save(&reservation).Error; err != nil {
+	tx.Commit()
+
+	return c.JSON(fiber.Map{"message": "Reservation cancelled by admin"})
+}
+
+// AdminCheckInReservation records that the customer arrived for their session
+func (rc *ReservationController) AdminCheckInReservation(c *fiber.Ctx) error {
+	tx := domain.WithActor(rc.DB.Begin(), actorFromContext(c, domain.SourceAdmin))
+
+	var reservation models.Reservation
+	if err := tx.First(&reservation, "id = ?", c.Params("id")).Error; err != nil {
 		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel"})
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
 	}
 
-	// Release schedule
-	if err := tx.Model(&models.Schedule{}).
-		Where("id = ?", reservation.ScheduleID).
-		Update("is_available", true).Error; err != nil {
+	if err := domain.CheckInReservation(tx, &reservation); err != nil {
 		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to release schedule"})
+		return transitionErrorResponse(c, err, "Failed to check in")
 	}
 
 	tx.Commit()
 
-	return c.JSON(fiber.Map{"message": "Reservation cancelled by admin"})
+	return c.JSON(fiber.Map{"message": "Checked in", "checked_in_at": reservation.CheckedInAt})
+}
+
+// orderIDFor returns the Midtrans order a reservation is paid under
+func orderIDFor(r *models.Reservation) string {
+	if r.BookingID != nil {
+		return *r.BookingID
+	}
+	return r.ID
+}
+
+// bookingPaymentsFor returns the booking payments sharing r's Midtrans order:
+// every payment of the cart for cart reservations, otherwise r's own payment,
+// created if missing
+func bookingPaymentsFor(tx *gorm.DB, r *models.Reservation) ([]models.Payment, error) {
+	var payments []models.Payment
+
+	if r.BookingID != nil {
+		err := tx.Where("gateway_order_id = ? AND kind = ?", *r.BookingID, models.PaymentKindBooking).
+			Order("id ASC").
+			Find(&payments).Error
+		return payments, err
+	}
+
+	var payment models.Payment
+	if err := tx.Where("reservation_id = ? AND kind = ?", r.ID, models.PaymentKindBooking).First(&payment).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		payment = models.Payment{
+			ReservationID:   r.ID,
+			MidtransOrderID: r.ID,
+			Amount:          r.TotalAmount,
+			Status:          domain.PaymentPending,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return append(payments, payment), nil
 }
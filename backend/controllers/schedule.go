@@ -3,9 +3,13 @@ package controllers
 import (
 	"time"
 
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
 	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ScheduleController struct {
@@ -43,12 +47,12 @@ func (sc *ScheduleController) GetAdminSchedules(c *fiber.Ctx) error {
 
 // BulkCreateInput defines payload for generating schedules
 type BulkCreateInput struct {
-	CourtID   string `json:"court_id"`
-	StartDate string `json:"start_date"` // YYYY-MM-DD
-	EndDate   string `json:"end_date"`   // YYYY-MM-DD
-	StartTime string `json:"start_time"` // HH:MM
-	EndTime   string `json:"end_time"`   // HH:MM
-	Duration  int    `json:"duration"`   // in minutes
+	CourtID   string `json:"court_id" validate:"required,uuid"`
+	StartDate string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"required,datetime=2006-01-02"`
+	StartTime string `json:"start_time" validate:"required,datetime=15:04"` // studio local time
+	EndTime   string `json:"end_time" validate:"required,datetime=15:04"`
+	Duration  int    `json:"duration"` // in minutes
 }
 
 // CreateScheduleBulk generates slots
@@ -58,53 +62,209 @@ func (sc *ScheduleController) CreateScheduleBulk(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
 	start, _ := time.Parse("2006-01-02", input.StartDate)
 	end, _ := time.Parse("2006-01-02", input.EndDate)
+	startTime, _ := models.ParseTimeOfDay(input.StartTime)
+	endTime, _ := models.ParseTimeOfDay(input.EndTime)
+	if endTime <= startTime {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "End time must be after start time"})
+	}
+
+	// Days the studio is closed (holiday, outside operating hours, blackout) are skipped
+	calendar, err := domain.LoadStudioCalendar(sc.DB, input.CourtID, start, end)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load studio calendar"})
+	}
 
-	// Basic validation loop
 	var schedules []models.Schedule
+	skipped := []fiber.Map{}
+	conflicts := []fiber.Map{}
 	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
-		// Needs robust time logic for slots, keeping it simple for now: single slot per day or loop?
-		// Requirement implies "Generate for date range".
-		// Let's assume input creates ONE slot per day in that range for simplicity,
-		// OR multiple slots if we loop time.
-		// For now, let's just create the specialized slot given in input for each day.
+		// One slot per day in the range, at the given time
+		if reason := calendar.Closure(d, startTime, endTime); reason != "" {
+			skipped = append(skipped, fiber.Map{"date": d.Format("2006-01-02"), "reason": reason})
+			continue
+		}
+
+		clashes, err := domain.OverlappingSchedules(sc.DB, input.CourtID, d, startTime, endTime, "")
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check schedules"})
+		}
+		if len(clashes) > 0 {
+			conflicts = append(conflicts, fiber.Map{"date": d.Format("2006-01-02"), "conflicting_schedule_ids": scheduleIDs(clashes)})
+			continue
+		}
 
 		schedules = append(schedules, models.Schedule{
 			CourtID:     input.CourtID,
 			Date:        d,
-			StartTime:   input.StartTime,
-			EndTime:     input.EndTime,
+			StartTime:   startTime,
+			EndTime:     endTime,
 			IsAvailable: true,
 		})
 	}
 
-	if err := sc.DB.Create(&schedules).Error; err != nil {
+	// Nothing is created while any day overlaps an existing slot
+	if len(conflicts) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Some slots overlap existing schedules", "conflicts": conflicts})
+	}
+
+	if len(schedules) == 0 {
+		return c.JSON(fiber.Map{"message": "No schedules created", "count": 0, "skipped": skipped})
+	}
+
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&schedules).Error; err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(schedules))
+		for _, s := range schedules {
+			ids = append(ids, s.ID)
+		}
+		return events.Publish(tx, events.ScheduleChanged, input.CourtID, events.ScheduleChangedPayload{
+			Action:      "created",
+			ScheduleIDs: ids,
+			CourtID:     input.CourtID,
+		})
+	})
+	if domain.IsScheduleOverlap(err) {
+		// A concurrent write took some of the slots; report which, as above
+		for _, s := range schedules {
+			clashes, _ := domain.OverlappingSchedules(sc.DB, s.CourtID, s.Date, s.StartTime, s.EndTime, "")
+			if len(clashes) > 0 {
+				conflicts = append(conflicts, fiber.Map{"date": s.Date.Format("2006-01-02"), "conflicting_schedule_ids": scheduleIDs(clashes)})
+			}
+		}
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Another schedule was created for these times, try again", "conflicts": conflicts})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to bulk create schedules"})
 	}
 
-	return c.JSON(fiber.Map{"message": "Schedules created", "count": len(schedules)})
+	return c.JSON(fiber.Map{"message": "Schedules created", "count": len(schedules), "skipped": skipped})
+}
+
+// UpdateScheduleInput edits a single slot; omitted fields are left unchanged
+type UpdateScheduleInput struct {
+	IsAvailable *bool   `json:"is_available"`
+	Date        *string `json:"date" validate:"omitempty,datetime=2006-01-02"`
+	StartTime   *string `json:"start_time" validate:"omitempty,datetime=15:04"`
+	EndTime     *string `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Force       bool    `json:"force"` // move a slot that holds active reservations
 }
 
-// UpdateSchedule toggles availability or edits time
+// UpdateSchedule toggles availability or moves the slot to another date or time
 func (sc *ScheduleController) UpdateSchedule(c *fiber.Ctx) error {
-	id := c.Params("id")
+	var input UpdateScheduleInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	tx := sc.DB.Begin()
+
 	var schedule models.Schedule
-	if err := sc.DB.First(&schedule, "id = ?", id).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, "id = ?", c.Params("id")).Error; err != nil {
+		tx.Rollback()
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
 	}
 
-	var input models.Schedule
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	if input.IsAvailable != nil {
+		schedule.IsAvailable = *input.IsAvailable
 	}
 
-	schedule.IsAvailable = input.IsAvailable
-	// Allow editing time if needed, but usually just availability toggle
+	moved := input.Date != nil || input.StartTime != nil || input.EndTime != nil
+	if moved {
+		if input.Date != nil {
+			schedule.Date, _ = time.Parse("2006-01-02", *input.Date)
+		}
+		if input.StartTime != nil {
+			schedule.StartTime, _ = models.ParseTimeOfDay(*input.StartTime)
+		}
+		if input.EndTime != nil {
+			schedule.EndTime, _ = models.ParseTimeOfDay(*input.EndTime)
+		}
+
+		if schedule.IsPrivate {
+			tx.Rollback()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Private session times cannot be edited"})
+		}
+		if schedule.EndTime <= schedule.StartTime {
+			tx.Rollback()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "End time must be after start time"})
+		}
+
+		calendar, err := domain.LoadStudioCalendar(tx, schedule.CourtID, schedule.Date, schedule.Date)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load studio calendar"})
+		}
+		if reason := calendar.Closure(schedule.Date, schedule.StartTime, schedule.EndTime); reason != "" {
+			tx.Rollback()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Studio is closed at that time", "reason": reason})
+		}
+
+		clashes, err := domain.OverlappingSchedules(tx, schedule.CourtID, schedule.Date, schedule.StartTime, schedule.EndTime, schedule.ID)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check schedules"})
+		}
+		if len(clashes) > 0 {
+			tx.Rollback()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Schedule overlaps other schedules", "conflicting_schedule_ids": scheduleIDs(clashes)})
+		}
+
+		booked, err := domain.BookedSeats(tx, schedule.ID)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check reservations"})
+		}
+		if booked > 0 && !input.Force {
+			tx.Rollback()
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Schedule has active reservations; resend with force to move it", "seats_booked": booked})
+		}
+	}
 
-	if err := sc.DB.Save(&schedule).Error; err != nil {
+	// Moved slots are announced as shifted so reminders follow the new time
+	action := "updated"
+	if moved {
+		action = "shifted"
+	}
+
+	if err := tx.Model(&schedule).Select("is_available", "date", "start_time", "end_time").Updates(&schedule).Error; err != nil {
+		tx.Rollback()
+		if domain.IsScheduleOverlap(err) {
+			return sc.overlapResponse(c, &schedule)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update schedule"})
+	}
+	if err := events.Publish(tx, events.ScheduleChanged, schedule.ID, events.ScheduleChangedPayload{
+		Action:      action,
+		ScheduleIDs: []string{schedule.ID},
+		CourtID:     schedule.CourtID,
+	}); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update schedule"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update schedule"})
 	}
 
 	return c.JSON(fiber.Map{"message": "Schedule updated", "data": schedule})
 }
+
+// overlapResponse answers a write rejected by the overlap constraint, listing
+// the schedules that took the slot in the meantime
+func (sc *ScheduleController) overlapResponse(c *fiber.Ctx, s *models.Schedule) error {
+	clashes, _ := domain.OverlappingSchedules(sc.DB, s.CourtID, s.Date, s.StartTime, s.EndTime, s.ID)
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Schedule overlaps other schedules", "conflicting_schedule_ids": scheduleIDs(clashes)})
+}
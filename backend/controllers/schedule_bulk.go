@@ -0,0 +1,713 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxBulkRangeDays bounds how many days a single bulk operation may touch
+const maxBulkRangeDays = 366
+
+// Planned actions of a bulk operation
+const (
+	bulkCreate  = "create"
+	bulkDelete  = "delete"
+	bulkClose   = "close"
+	bulkShift   = "shift"
+	bulkUpdate  = "update"
+	bulkSkip    = "skip"    // left alone, see reason
+	bulkBlocked = "blocked" // prevents the whole operation, see reason
+)
+
+// errBulkDryRun rolls back a bulk operation after its plan has been built
+var errBulkDryRun = errors.New("dry run")
+
+// bulkRefusal stops a bulk operation; nothing is written
+type bulkRefusal struct {
+	message string
+}
+
+func (e *bulkRefusal) Error() string { return e.message }
+
+// BulkRangeInput selects the public schedules of one court between two dates.
+// Private sessions from session requests are never touched by bulk edits.
+type BulkRangeInput struct {
+	CourtID   string `json:"court_id" validate:"required,uuid"`
+	StartDate string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"required,datetime=2006-01-02"`
+	DryRun    bool   `json:"dry_run"` // return the plan without writing anything
+	Force     bool   `json:"force"`   // go ahead even when slots hold active reservations
+}
+
+type ShiftScheduleRangeInput struct {
+	BulkRangeInput
+	OffsetMinutes int `json:"offset_minutes" validate:"required,min=-720,max=720"`
+}
+
+type UpdateScheduleRangeInput struct {
+	BulkRangeInput
+	Price         *float64 `json:"price" validate:"omitempty,gt=0"`
+	Capacity      *int     `json:"capacity" validate:"omitempty,gt=0"`
+	ResetPrice    bool     `json:"reset_price"`    // fall back to the court's price
+	ResetCapacity bool     `json:"reset_capacity"` // fall back to the court's capacity
+}
+
+type CopyWeekInput struct {
+	CourtID         string `json:"court_id" validate:"omitempty,uuid"` // every court when empty
+	SourceWeekStart string `json:"source_week_start" validate:"required,datetime=2006-01-02"`
+	TargetStart     string `json:"target_start" validate:"required,datetime=2006-01-02"`
+	TargetEnd       string `json:"target_end" validate:"omitempty,datetime=2006-01-02"` // defaults to one week
+	DryRun          bool   `json:"dry_run"`
+}
+
+// bulkScheduleChange is one line of a bulk operation's plan
+type bulkScheduleChange struct {
+	ScheduleID   string            `json:"schedule_id,omitempty"`
+	CourtID      string            `json:"court_id"`
+	Date         string            `json:"date"`
+	StartTime    models.TimeOfDay  `json:"start_time"`
+	EndTime      models.TimeOfDay  `json:"end_time"`
+	NewStartTime *models.TimeOfDay `json:"new_start_time,omitempty"`
+	NewEndTime   *models.TimeOfDay `json:"new_end_time,omitempty"`
+	SeatsBooked  int               `json:"seats_booked"`
+	Action       string            `json:"action"`
+	Reason       string            `json:"reason,omitempty"`
+	// ConflictingScheduleIDs are the slots a created or shifted slot would overlap
+	ConflictingScheduleIDs []string `json:"conflicting_schedule_ids,omitempty"`
+}
+
+func newBulkScheduleChange(s *models.Schedule, seats int, action string) bulkScheduleChange {
+	return bulkScheduleChange{
+		ScheduleID:  s.ID,
+		CourtID:     s.CourtID,
+		Date:        s.Date.Format("2006-01-02"),
+		StartTime:   s.StartTime,
+		EndTime:     s.EndTime,
+		SeatsBooked: seats,
+		Action:      action,
+	}
+}
+
+// parseBulkRange parses an inclusive date range of at most maxBulkRangeDays
+func parseBulkRange(startDate, endDate string) (time.Time, time.Time, error) {
+	start, _ := time.Parse("2006-01-02", startDate)
+	end, _ := time.Parse("2006-01-02", endDate)
+	if end.Before(start) {
+		return start, end, errors.New("End date must not be before start date")
+	}
+	if daysBetween(start, end) >= maxBulkRangeDays {
+		return start, end, errors.New("Date range is too long")
+	}
+	return start, end, nil
+}
+
+// daysBetween counts calendar days from a to b, ignoring time and location
+func daysBetween(a, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// lockScheduleRange loads and locks the public schedules selected by input
+// together with the seats their active reservations hold
+func lockScheduleRange(tx *gorm.DB, input BulkRangeInput, start, end time.Time) ([]models.Schedule, map[string]int, error) {
+	var schedules []models.Schedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("court_id = ? AND date BETWEEN ? AND ? AND is_private = ?",
+			input.CourtID, start.Format("2006-01-02"), end.Format("2006-01-02"), false).
+		Order("date ASC, start_time ASC").
+		Find(&schedules).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(schedules) == 0 {
+		return schedules, map[string]int{}, nil
+	}
+
+	seats, err := domain.BookedSeatsBySchedule(tx, scheduleIDs(schedules))
+	return schedules, seats, err
+}
+
+func scheduleIDs(schedules []models.Schedule) []string {
+	ids := make([]string, 0, len(schedules))
+	for _, s := range schedules {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// checkBulkPlan decides whether a plan may be applied. Dry runs always return
+// the plan; otherwise blocked lines refuse the operation, and so do slots with
+// active reservations unless force is set.
+func checkBulkPlan(changes []bulkScheduleChange, dryRun, force bool) error {
+	if dryRun {
+		return errBulkDryRun
+	}
+	for _, change := range changes {
+		if change.Action == bulkBlocked {
+			return &bulkRefusal{message: "Some slots cannot be changed"}
+		}
+	}
+	if !force && bulkRequiresForce(changes) {
+		return &bulkRefusal{message: "Some slots have active reservations; resend with force to apply"}
+	}
+	return nil
+}
+
+// bulkOverlaps looks up, after the overlap constraint rejected a bulk write,
+// which planned slots now clash with a schedule written in the meantime.
+// Slots moved by the same operation do not count.
+func (sc *ScheduleController) bulkOverlaps(changes []bulkScheduleChange) []bulkScheduleChange {
+	planned := map[string]bool{}
+	for _, change := range changes {
+		if change.ScheduleID != "" {
+			planned[change.ScheduleID] = true
+		}
+	}
+
+	overlaps := []bulkScheduleChange{}
+	for _, change := range changes {
+		start, end := change.StartTime, change.EndTime
+		switch {
+		case change.Action == bulkShift && change.NewStartTime != nil && change.NewEndTime != nil:
+			start, end = *change.NewStartTime, *change.NewEndTime
+		case change.Action != bulkCreate:
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", change.Date)
+		if err != nil {
+			continue
+		}
+		clashes, err := domain.OverlappingSchedules(sc.DB, change.CourtID, date, start, end, change.ScheduleID)
+		if err != nil {
+			continue
+		}
+
+		change.ConflictingScheduleIDs = nil
+		for _, clash := range clashes {
+			if !planned[clash.ID] {
+				change.ConflictingScheduleIDs = append(change.ConflictingScheduleIDs, clash.ID)
+			}
+		}
+		if len(change.ConflictingScheduleIDs) > 0 {
+			change.Action, change.Reason = bulkBlocked, "overlaps another schedule"
+			overlaps = append(overlaps, change)
+		}
+	}
+	return overlaps
+}
+
+func bulkRequiresForce(changes []bulkScheduleChange) bool {
+	for _, change := range changes {
+		if change.SeatsBooked > 0 && change.Action != bulkSkip && change.Action != bulkBlocked {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkResponse renders the outcome of a bulk operation transaction
+func (sc *ScheduleController) bulkResponse(c *fiber.Ctx, err error, changes []bulkScheduleChange, force bool, message, failure string) error {
+	var refusal *bulkRefusal
+	switch {
+	case errors.As(err, &refusal):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": refusal.message, "changes": changes})
+	case domain.IsScheduleOverlap(err):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Another schedule was created for these times, preview again",
+			"changes": sc.bulkOverlaps(changes),
+		})
+	case err != nil && !errors.Is(err, errBulkDryRun):
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": failure})
+	}
+
+	count := 0
+	for _, change := range changes {
+		if change.Action != bulkSkip && change.Action != bulkBlocked {
+			count++
+		}
+	}
+
+	dryRun := errors.Is(err, errBulkDryRun)
+	if dryRun {
+		message = "Dry run, nothing was changed"
+	}
+	return c.JSON(fiber.Map{
+		"message":        message,
+		"dry_run":        dryRun,
+		"count":          count,
+		"requires_force": !force && bulkRequiresForce(changes),
+		"changes":        changes,
+	})
+}
+
+// CopyScheduleWeek copies the public schedules of one week (seven days from
+// source_week_start) onto every week from target_start to target_end. Copies
+// that would overlap an existing slot or fall on a closed day are skipped, so
+// slots with reservations are never touched.
+func (sc *ScheduleController) CopyScheduleWeek(c *fiber.Ctx) error {
+	var input CopyWeekInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	sourceStart, _ := time.Parse("2006-01-02", input.SourceWeekStart)
+	sourceEnd := sourceStart.AddDate(0, 0, 6)
+	if input.TargetEnd == "" {
+		targetStart, _ := time.Parse("2006-01-02", input.TargetStart)
+		input.TargetEnd = targetStart.AddDate(0, 0, 6).Format("2006-01-02")
+	}
+	targetStart, targetEnd, err := parseBulkRange(input.TargetStart, input.TargetEnd)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !targetStart.After(sourceEnd) && !targetEnd.Before(sourceStart) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Target range must not overlap the source week"})
+	}
+
+	changes := []bulkScheduleChange{}
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("date BETWEEN ? AND ? AND is_private = ?",
+			sourceStart.Format("2006-01-02"), sourceEnd.Format("2006-01-02"), false)
+		if input.CourtID != "" {
+			query = query.Where("court_id = ?", input.CourtID)
+		}
+		var source []models.Schedule
+		if err := query.Order("date ASC, start_time ASC").Find(&source).Error; err != nil {
+			return err
+		}
+
+		calendars := map[string]*domain.StudioCalendar{}
+		var copies []models.Schedule
+		for week := targetStart; !week.After(targetEnd); week = week.AddDate(0, 0, 7) {
+			for _, s := range source {
+				date := week.AddDate(0, 0, daysBetween(sourceStart, s.Date))
+				if date.After(targetEnd) {
+					continue
+				}
+
+				change := bulkScheduleChange{
+					CourtID:   s.CourtID,
+					Date:      date.Format("2006-01-02"),
+					StartTime: s.StartTime,
+					EndTime:   s.EndTime,
+					Action:    bulkCreate,
+				}
+
+				calendar, ok := calendars[s.CourtID]
+				if !ok {
+					var err error
+					if calendar, err = domain.LoadStudioCalendar(tx, s.CourtID, targetStart, targetEnd); err != nil {
+						return err
+					}
+					calendars[s.CourtID] = calendar
+				}
+				if reason := calendar.Closure(date, s.StartTime, s.EndTime); reason != "" {
+					change.Action, change.Reason = bulkSkip, reason
+					changes = append(changes, change)
+					continue
+				}
+
+				clashes, err := domain.OverlappingSchedules(tx, s.CourtID, date, s.StartTime, s.EndTime, "")
+				if err != nil {
+					return err
+				}
+				if len(clashes) > 0 {
+					change.Action, change.Reason = bulkSkip, "overlaps an existing schedule"
+					change.ConflictingScheduleIDs = scheduleIDs(clashes)
+					changes = append(changes, change)
+					continue
+				}
+
+				changes = append(changes, change)
+				copies = append(copies, models.Schedule{
+					CourtID:     s.CourtID,
+					Date:        date,
+					StartTime:   s.StartTime,
+					EndTime:     s.EndTime,
+					IsAvailable: true,
+					Price:       s.Price,
+					Capacity:    s.Capacity,
+				})
+			}
+		}
+
+		if err := checkBulkPlan(changes, input.DryRun, false); err != nil {
+			return err
+		}
+		if len(copies) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&copies).Error; err != nil {
+			return err
+		}
+
+		byCourt := map[string][]string{}
+		for _, s := range copies {
+			byCourt[s.CourtID] = append(byCourt[s.CourtID], s.ID)
+		}
+		for courtID, ids := range byCourt {
+			if err := events.Publish(tx, events.ScheduleChanged, courtID, events.ScheduleChangedPayload{
+				Action:      "created",
+				ScheduleIDs: ids,
+				CourtID:     courtID,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return sc.bulkResponse(c, err, changes, false, "Schedules copied", "Failed to copy schedules")
+}
+
+// DeleteScheduleRange deletes the public schedules of a court in a date range.
+// Slots that hold reservations are closed instead so their history is kept;
+// active reservations require force and stay in place for the admin to
+// cancel or move.
+func (sc *ScheduleController) DeleteScheduleRange(c *fiber.Ctx) error {
+	var input BulkRangeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	start, end, err := parseBulkRange(input.StartDate, input.EndDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	changes := []bulkScheduleChange{}
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		schedules, seats, err := lockScheduleRange(tx, input, start, end)
+		if err != nil {
+			return err
+		}
+		if len(schedules) == 0 {
+			return checkBulkPlan(changes, input.DryRun, input.Force)
+		}
+
+		// Any reservation, even a cancelled one, keeps its schedule row
+		var withHistory []string
+		if err := tx.Model(&models.Reservation{}).
+			Where("schedule_id IN ?", scheduleIDs(schedules)).
+			Distinct().Pluck("schedule_id", &withHistory).Error; err != nil {
+			return err
+		}
+		hasHistory := map[string]bool{}
+		for _, id := range withHistory {
+			hasHistory[id] = true
+		}
+
+		var deleteIDs, closeIDs []string
+		dates := map[string]string{} // of the deleted schedules, for the streams
+		for i := range schedules {
+			s := &schedules[i]
+			change := newBulkScheduleChange(s, seats[s.ID], bulkDelete)
+			if hasHistory[s.ID] {
+				change.Action, change.Reason = bulkClose, "has reservations"
+				if !s.IsAvailable {
+					change.Action = bulkSkip
+				}
+			}
+			switch change.Action {
+			case bulkDelete:
+				deleteIDs = append(deleteIDs, s.ID)
+				dates[s.ID] = s.Date.Format("2006-01-02")
+			case bulkClose:
+				closeIDs = append(closeIDs, s.ID)
+			}
+			changes = append(changes, change)
+		}
+
+		if err := checkBulkPlan(changes, input.DryRun, input.Force); err != nil {
+			return err
+		}
+
+		if len(deleteIDs) > 0 {
+			if err := tx.Where("id IN ?", deleteIDs).Delete(&models.Schedule{}).Error; err != nil {
+				return err
+			}
+			if err := events.Publish(tx, events.ScheduleChanged, input.CourtID, events.ScheduleChangedPayload{
+				Action:      "deleted",
+				ScheduleIDs: deleteIDs,
+				CourtID:     input.CourtID,
+				Dates:       dates,
+			}); err != nil {
+				return err
+			}
+		}
+		return closeSchedules(tx, input.CourtID, closeIDs)
+	})
+
+	return sc.bulkResponse(c, err, changes, input.Force, "Schedules deleted", "Failed to delete schedules")
+}
+
+// CloseScheduleRange stops new bookings on the public schedules of a court in
+// a date range. Existing reservations are kept; closing slots that hold
+// active ones requires force.
+func (sc *ScheduleController) CloseScheduleRange(c *fiber.Ctx) error {
+	var input BulkRangeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	start, end, err := parseBulkRange(input.StartDate, input.EndDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	changes := []bulkScheduleChange{}
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		schedules, seats, err := lockScheduleRange(tx, input, start, end)
+		if err != nil {
+			return err
+		}
+
+		var closeIDs []string
+		for i := range schedules {
+			s := &schedules[i]
+			change := newBulkScheduleChange(s, seats[s.ID], bulkClose)
+			if !s.IsAvailable {
+				change.Action, change.Reason = bulkSkip, "already closed"
+			} else {
+				closeIDs = append(closeIDs, s.ID)
+			}
+			changes = append(changes, change)
+		}
+
+		if err := checkBulkPlan(changes, input.DryRun, input.Force); err != nil {
+			return err
+		}
+		return closeSchedules(tx, input.CourtID, closeIDs)
+	})
+
+	return sc.bulkResponse(c, err, changes, input.Force, "Schedules closed", "Failed to close schedules")
+}
+
+func closeSchedules(tx *gorm.DB, courtID string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Schedule{}).Where("id IN ?", ids).Update("is_available", false).Error; err != nil {
+		return err
+	}
+	return events.Publish(tx, events.ScheduleChanged, courtID, events.ScheduleChangedPayload{
+		Action:      "closed",
+		ScheduleIDs: ids,
+		CourtID:     courtID,
+	})
+}
+
+// ShiftScheduleRange moves the public schedules of a court in a date range by
+// offset_minutes. The whole operation is refused if any slot would cross
+// midnight, fall outside opening hours or overlap a slot outside the range.
+// Shifting slots with active reservations requires force; their reminders
+// follow the new time.
+func (sc *ScheduleController) ShiftScheduleRange(c *fiber.Ctx) error {
+	var input ShiftScheduleRangeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	start, end, err := parseBulkRange(input.StartDate, input.EndDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	offset := time.Duration(input.OffsetMinutes) * time.Minute
+
+	changes := []bulkScheduleChange{}
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		schedules, seats, err := lockScheduleRange(tx, input.BulkRangeInput, start, end)
+		if err != nil {
+			return err
+		}
+		if len(schedules) == 0 {
+			return checkBulkPlan(changes, input.DryRun, input.Force)
+		}
+
+		calendar, err := domain.LoadStudioCalendar(tx, input.CourtID, start, end)
+		if err != nil {
+			return err
+		}
+
+		shifting := map[string]bool{}
+		for _, s := range schedules {
+			shifting[s.ID] = true
+		}
+
+		for i := range schedules {
+			s := &schedules[i]
+			newStart, newEnd := s.StartTime.Add(offset), s.EndTime.Add(offset)
+			change := newBulkScheduleChange(s, seats[s.ID], bulkShift)
+			change.NewStartTime, change.NewEndTime = &newStart, &newEnd
+
+			// A TIME column wraps 24:00 to 00:00, so ending at midnight is out too
+			if newStart < 0 || newEnd >= models.NewTimeOfDay(24, 0) {
+				change.Action, change.Reason = bulkBlocked, "would cross midnight"
+				changes = append(changes, change)
+				continue
+			}
+			if reason := calendar.Closure(s.Date, newStart, newEnd); reason != "" {
+				change.Action, change.Reason = bulkBlocked, reason
+				changes = append(changes, change)
+				continue
+			}
+
+			// Slots in the range move together, so only the others can clash
+			clashes, err := domain.OverlappingSchedules(tx, s.CourtID, s.Date, newStart, newEnd, s.ID)
+			if err != nil {
+				return err
+			}
+			for _, clash := range clashes {
+				if !shifting[clash.ID] {
+					change.Action, change.Reason = bulkBlocked, "overlaps another schedule"
+					change.ConflictingScheduleIDs = append(change.ConflictingScheduleIDs, clash.ID)
+				}
+			}
+			changes = append(changes, change)
+		}
+
+		if err := checkBulkPlan(changes, input.DryRun, input.Force); err != nil {
+			return err
+		}
+
+		ids := scheduleIDs(schedules)
+		if err := tx.Model(&models.Schedule{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"start_time": gorm.Expr("start_time + make_interval(mins => ?)", input.OffsetMinutes),
+			"end_time":   gorm.Expr("end_time + make_interval(mins => ?)", input.OffsetMinutes),
+		}).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.ScheduleChanged, input.CourtID, events.ScheduleChangedPayload{
+			Action:      "shifted",
+			ScheduleIDs: ids,
+			CourtID:     input.CourtID,
+		})
+	})
+
+	return sc.bulkResponse(c, err, changes, input.Force, "Schedules shifted", "Failed to shift schedules")
+}
+
+// UpdateScheduleRange sets or resets the price and capacity of the public
+// schedules of a court in a date range. Existing reservations keep the amount
+// they were booked at; capacity can never drop below the seats already
+// booked, and touching slots with active reservations requires force.
+func (sc *ScheduleController) UpdateScheduleRange(c *fiber.Ctx) error {
+	var input UpdateScheduleRangeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	updates := map[string]interface{}{}
+	switch {
+	case input.ResetPrice && input.Price != nil:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Give either price or reset_price"})
+	case input.ResetPrice:
+		updates["price"] = nil
+	case input.Price != nil:
+		updates["price"] = *input.Price
+	}
+	switch {
+	case input.ResetCapacity && input.Capacity != nil:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Give either capacity or reset_capacity"})
+	case input.ResetCapacity:
+		updates["capacity"] = nil
+	case input.Capacity != nil:
+		updates["capacity"] = *input.Capacity
+	}
+	if len(updates) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Nothing to update"})
+	}
+
+	start, end, err := parseBulkRange(input.StartDate, input.EndDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	changes := []bulkScheduleChange{}
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		schedules, seats, err := lockScheduleRange(tx, input.BulkRangeInput, start, end)
+		if err != nil {
+			return err
+		}
+		if len(schedules) == 0 {
+			return checkBulkPlan(changes, input.DryRun, input.Force)
+		}
+
+		var court models.Court
+		if err := tx.First(&court, "id = ?", input.CourtID).Error; err != nil {
+			return err
+		}
+		capacity := court.Capacity
+		if input.Capacity != nil {
+			capacity = *input.Capacity
+		}
+
+		for i := range schedules {
+			s := &schedules[i]
+			change := newBulkScheduleChange(s, seats[s.ID], bulkUpdate)
+			if _, ok := updates["capacity"]; ok && capacity < seats[s.ID] {
+				change.Action, change.Reason = bulkBlocked, "capacity below seats already booked"
+			}
+			changes = append(changes, change)
+		}
+
+		if err := checkBulkPlan(changes, input.DryRun, input.Force); err != nil {
+			return err
+		}
+
+		ids := scheduleIDs(schedules)
+		if err := tx.Model(&models.Schedule{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+			return err
+		}
+		if _, ok := updates["capacity"]; ok {
+			// Open slots may fill up and full ones may open; slots an admin
+			// closed by hand stay closed
+			for i := range schedules {
+				s := &schedules[i]
+				if !s.IsAvailable && seats[s.ID] < s.SeatCapacity(court) {
+					continue
+				}
+				if err := domain.SyncScheduleAvailability(tx, s.ID); err != nil {
+					return err
+				}
+			}
+		}
+		return events.Publish(tx, events.ScheduleChanged, input.CourtID, events.ScheduleChangedPayload{
+			Action:      "updated",
+			ScheduleIDs: ids,
+			CourtID:     input.CourtID,
+		})
+	})
+
+	return sc.bulkResponse(c, err, changes, input.Force, "Schedules updated", "Failed to update schedules")
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Giriathallah/diro-pilates-backend/services"
+)
+
+type SeatStreamController struct {
+	Stream *services.SeatStream
+}
+
+func NewSeatStreamController(stream *services.SeatStream) *SeatStreamController {
+	return &SeatStreamController{Stream: stream}
+}
+
+// StreamSeats pushes seat count changes for a date and/or court as
+// Server-Sent Events. A new connection starts with a "snapshot" event; a
+// reconnect carrying Last-Event-ID (or ?last_event_id=) receives only the
+// "seats" events it missed, or a fresh snapshot if the cursor is too old.
+// GET /api/schedules/stream?date=YYYY-MM-DD&court_id=...
+func (sc *SeatStreamController) StreamSeats(c *fiber.Ctx) error {
+	date := c.Query("date")
+	courtID := c.Query("court_id")
+
+	if date == "" && courtID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "date or court_id is required"})
+	}
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid date format (YYYY-MM-DD)"})
+		}
+	}
+
+	sub, missed, resumed, cursor := sc.Stream.Subscribe(services.SeatFilter(date, courtID), lastEventID(c))
+
+	var snapshot []services.SeatUpdate
+	if !resumed {
+		var err error
+		snapshot, err = sc.Stream.Snapshot(date, courtID)
+		if err != nil {
+			sc.Stream.Unsubscribe(sub)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch seat counts"})
+		}
+		if snapshot == nil {
+			snapshot = []services.SeatUpdate{}
+		}
+	}
+
+	setSSEHeaders(c)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sc.Stream.Unsubscribe(sub)
+
+		if writeSSERetry(w) != nil {
+			return
+		}
+
+		if resumed {
+			for _, m := range missed {
+				if writeSSE(w, m.ID, "seats", m.Data) != nil {
+					return
+				}
+			}
+		} else if writeSSE(w, cursor, "snapshot", snapshot) != nil {
+			return
+		}
+
+		streamSSE(w, sub.Messages, "seats")
+	})
+
+	return nil
+}
@@ -0,0 +1,416 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const defaultSessionRequestTTLHours = 48
+
+type SessionRequestController struct {
+	DB       *gorm.DB
+	Midtrans *services.MidtransService
+}
+
+func NewSessionRequestController(db *gorm.DB, mt *services.MidtransService) *SessionRequestController {
+	return &SessionRequestController{DB: db, Midtrans: mt}
+}
+
+type CreateSessionRequestInput struct {
+	CourtID             *string `json:"court_id" validate:"omitempty,uuid"`
+	PreferredInstructor string  `json:"preferred_instructor" validate:"max=100"`
+	ClassType           string  `json:"class_type" validate:"required,max=50"`
+	Date                string  `json:"date" validate:"required,datetime=2006-01-02"`
+	StartTime           string  `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime             string  `json:"end_time" validate:"required,datetime=15:04"`
+	Notes               string  `json:"notes"`
+}
+
+type ApproveSessionRequestInput struct {
+	// CourtID is required when the customer only named an instructor
+	CourtID *string  `json:"court_id" validate:"omitempty,uuid"`
+	Price   *float64 `json:"price" validate:"omitempty,gte=0"` // defaults to the court price
+}
+
+type DeclineSessionRequestInput struct {
+	Reason string `json:"reason" validate:"required,min=3"`
+}
+
+// CreateSessionRequest proposes a private session
+// POST /api/session-requests
+func (sc *SessionRequestController) CreateSessionRequest(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var input CreateSessionRequestInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	if input.CourtID == nil && input.PreferredInstructor == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Choose a court or an instructor"})
+	}
+	startTime, _ := models.ParseTimeOfDay(input.StartTime)
+	endTime, _ := models.ParseTimeOfDay(input.EndTime)
+	if endTime <= startTime {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "End time must be after start time"})
+	}
+
+	date, _ := time.Parse("2006-01-02", input.Date)
+	request := models.SessionRequest{
+		UserID:              userID,
+		CourtID:             input.CourtID,
+		PreferredInstructor: input.PreferredInstructor,
+		ClassType:           input.ClassType,
+		Date:                date,
+		StartTime:           startTime,
+		EndTime:             endTime,
+		Notes:               input.Notes,
+		Status:              models.SessionRequestPending,
+	}
+
+	start := domain.ScheduleStart(&models.Schedule{Date: date, StartTime: startTime})
+	if !start.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Requested time must be in the future"})
+	}
+
+	if input.CourtID != nil {
+		var court models.Court
+		if err := sc.DB.First(&court, "id = ? AND is_active = ?", *input.CourtID, true).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Court not found"})
+		}
+	}
+
+	// Unanswered requests lapse, at the latest when the session would have started
+	request.ExpiresAt = time.Now().Add(time.Duration(envInt("SESSION_REQUEST_TTL_HOURS", defaultSessionRequestTTLHours)) * time.Hour)
+	if start.Before(request.ExpiresAt) {
+		request.ExpiresAt = start
+	}
+
+	if err := sc.DB.Create(&request).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create request"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"data": request})
+}
+
+// GetMySessionRequests lists the caller's requests
+// GET /api/session-requests/my
+func (sc *SessionRequestController) GetMySessionRequests(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var requests []models.SessionRequest
+	if err := sc.DB.Preload("Court").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&requests).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch requests"})
+	}
+
+	return c.JSON(fiber.Map{"data": requests})
+}
+
+// GetSessionRequest returns one of the caller's requests, including the payment token once approved
+// GET /api/session-requests/:id
+func (sc *SessionRequestController) GetSessionRequest(c *fiber.Ctx) error {
+	var request models.SessionRequest
+	if err := sc.DB.Preload("Court").First(&request, "id = ? AND user_id = ?", c.Params("id"), c.Locals("user_id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Request not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": request})
+}
+
+// CancelSessionRequest withdraws a request that has not been answered yet
+// POST /api/session-requests/:id/cancel
+func (sc *SessionRequestController) CancelSessionRequest(c *fiber.Ctx) error {
+	result := sc.DB.Model(&models.SessionRequest{}).
+		Where("id = ? AND user_id = ? AND status = ?", c.Params("id"), c.Locals("user_id"), models.SessionRequestPending).
+		Update("status", models.SessionRequestCancelled)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel request"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only pending requests can be cancelled"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Request cancelled"})
+}
+
+// GetSessionRequests lists requests for admins, optionally filtered by status
+// GET /api/admin/session-requests
+func (sc *SessionRequestController) GetSessionRequests(c *fiber.Ctx) error {
+	db := sc.DB.Preload("Court").Preload("User")
+	if status := c.Query("status"); status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var requests []models.SessionRequest
+	if err := db.Order("date ASC, start_time ASC").Find(&requests).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch requests"})
+	}
+
+	data := make([]fiber.Map, 0, len(requests))
+	for _, r := range requests {
+		data = append(data, fiber.Map{
+			"request":        r,
+			"customer_name":  r.User.Name,
+			"customer_email": r.User.Email,
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": data})
+}
+
+// ApproveSessionRequest creates a private schedule and a pending reservation with a payment token
+// POST /api/admin/session-requests/:id/approve
+func (sc *SessionRequestController) ApproveSessionRequest(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(string)
+
+	var input ApproveSessionRequestInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	tx := domain.WithActor(sc.DB.Begin(), actorFromContext(c, domain.SourceAdmin))
+
+	var request models.SessionRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, "id = ?", c.Params("id")).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Request not found"})
+	}
+
+	if request.Status != models.SessionRequestPending || time.Now().After(request.ExpiresAt) {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only pending requests can be approved", "status": request.Status})
+	}
+
+	courtID := request.CourtID
+	if input.CourtID != nil {
+		courtID = input.CourtID
+	}
+	if courtID == nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "court_id is required to approve this request"})
+	}
+
+	var court models.Court
+	if err := tx.First(&court, "id = ?", *courtID).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Court not found"})
+	}
+
+	// The court must be free for the whole requested time
+	clashes, err := domain.OverlappingSchedules(tx, court.ID, request.Date, request.StartTime, request.EndTime, "")
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check schedule"})
+	}
+	if len(clashes) > 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Court is already scheduled at that time", "conflicting_schedule_ids": scheduleIDs(clashes)})
+	}
+
+	price := court.PricePerSlot
+	if input.Price != nil {
+		price = *input.Price
+	}
+
+	// 1. Dedicated schedule, hidden from public booking
+	schedule := models.Schedule{
+		CourtID:     court.ID,
+		Date:        request.Date,
+		StartTime:   request.StartTime,
+		EndTime:     request.EndTime,
+		IsAvailable: false,
+		IsPrivate:   true,
+		Price:       &price,
+	}
+	if err := tx.Create(&schedule).Error; err != nil {
+		tx.Rollback()
+		if domain.IsScheduleOverlap(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Court was scheduled at that time in the meantime, try again"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create schedule"})
+	}
+	// Create skips zero-value fields that have a database default
+	tx.Model(&schedule).Update("is_available", false)
+
+	if err := events.Publish(tx, events.ScheduleChanged, schedule.ID, events.ScheduleChangedPayload{
+		Action:      "created",
+		ScheduleIDs: []string{schedule.ID},
+		CourtID:     court.ID,
+	}); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create schedule"})
+	}
+
+	// 2. Pending reservation for the requester
+	reservation := models.Reservation{
+		UserID:      request.UserID,
+		CourtID:     court.ID,
+		ScheduleID:  schedule.ID,
+		Seats:       1,
+		Status:      domain.ReservationPending,
+		TotalAmount: price,
+		Notes:       fmt.Sprintf("Private session (%s): %s", request.ClassType, request.Notes),
+	}
+	if err := tx.Create(&reservation).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+	}
+
+	payload := events.NewReservationPayload(&reservation, "")
+	if err := events.Publish(tx, events.ReservationCreated, reservation.ID, payload); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+	}
+	if err := domain.RecordReservationEvent(tx, reservation.ID, domain.TimelineCreated, "", reservation.Status, fiber.Map{
+		"session_request_id": request.ID,
+		"total_amount":       reservation.TotalAmount,
+	}); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+	}
+
+	// 3. Pending payment; the Snap token is requested once this is committed
+	var user models.User
+	if err := tx.First(&user, "id = ?", request.UserID).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "User data error"})
+	}
+
+	payment := models.Payment{
+		ReservationID:   reservation.ID,
+		MidtransOrderID: reservation.ID,
+		Amount:          reservation.TotalAmount,
+		Status:          domain.PaymentPending,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to init payment"})
+	}
+
+	requestedCourtID := request.CourtID
+	now := time.Now()
+	if err := tx.Model(&request).Updates(map[string]interface{}{
+		"status":         models.SessionRequestApproved,
+		"court_id":       court.ID,
+		"schedule_id":    schedule.ID,
+		"reservation_id": reservation.ID,
+		"reviewed_by":    adminID,
+		"reviewed_at":    now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to approve request"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to approve request"})
+	}
+
+	// 4. Payment token outside the transaction; the customer gets a longer
+	// window than regular bookings
+	token, redirectURL, err := sc.Midtrans.GenerateSnapTokenWithExpiry(&reservation, &user, services.PrivateSessionPaymentWindow())
+	if err != nil {
+		log.Println("Midtrans Error:", err)
+		sc.undoApproval(c, &request, requestedCourtID, &payment)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate payment token"})
+	}
+
+	if err := sc.DB.Model(&request).Updates(map[string]interface{}{
+		"snap_token":           token,
+		"payment_redirect_url": redirectURL,
+	}).Error; err != nil {
+		log.Printf("Failed to store payment token for session request %s: %v", request.ID, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Request approved",
+		"schedule_id":    schedule.ID,
+		"reservation_id": reservation.ID,
+		"snap_token":     token,
+		"redirect_url":   redirectURL,
+		"amount":         reservation.TotalAmount,
+	})
+}
+
+// undoApproval puts a request back to pending when no payment token could be
+// created for it. Failing the payment cancels the reservation, which releases
+// the private schedule, so the admin can simply approve again.
+func (sc *SessionRequestController) undoApproval(c *fiber.Ctx, request *models.SessionRequest, courtID *string, payment *models.Payment) {
+	tx := domain.WithActor(sc.DB.Begin(), actorFromContext(c, domain.SourceAdmin))
+
+	if err := domain.ApplyPaymentStatus(tx, payment, domain.PaymentFailed); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to undo approval of session request %s: %v", request.ID, err)
+		return
+	}
+
+	if err := tx.Model(request).Updates(map[string]interface{}{
+		"status":         models.SessionRequestPending,
+		"court_id":       courtID,
+		"schedule_id":    nil,
+		"reservation_id": nil,
+		"reviewed_by":    nil,
+		"reviewed_at":    nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to undo approval of session request %s: %v", request.ID, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Failed to undo approval of session request %s: %v", request.ID, err)
+	}
+}
+
+// DeclineSessionRequest rejects a pending request with a reason shown to the customer
+// POST /api/admin/session-requests/:id/decline
+func (sc *SessionRequestController) DeclineSessionRequest(c *fiber.Ctx) error {
+	var input DeclineSessionRequestInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	result := sc.DB.Model(&models.SessionRequest{}).
+		Where("id = ? AND status = ?", c.Params("id"), models.SessionRequestPending).
+		Updates(map[string]interface{}{
+			"status":         models.SessionRequestDeclined,
+			"decline_reason": input.Reason,
+			"reviewed_by":    c.Locals("user_id"),
+			"reviewed_at":    time.Now(),
+		})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decline request"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only pending requests can be declined"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Request declined"})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Giriathallah/diro-pilates-backend/services"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+func setSSEHeaders(c *fiber.Ctx) {
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+}
+
+// lastEventID is the cursor a reconnecting client resumes from. Clients that
+// cannot set headers pass it as ?last_event_id=.
+func lastEventID(c *fiber.Ctx) string {
+	if id := c.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query("last_event_id")
+}
+
+// writeSSERetry tells EventSource to reconnect quickly after a drop and
+// flushes the response headers
+func writeSSERetry(w *bufio.Writer) error {
+	fmt.Fprint(w, "retry: 3000\n\n")
+	return w.Flush()
+}
+
+// writeSSE writes and flushes one event. An error means the client has gone.
+func writeSSE(w *bufio.Writer, id, event string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return w.Flush()
+}
+
+// streamSSE relays broadcast messages as events, with heartbeats so proxies
+// keep the connection open. It returns when the client disconnects or the
+// subscription is dropped for lagging; the client then resumes from its
+// last id.
+func streamSSE[T any](w *bufio.Writer, messages <-chan services.Message[T], event string) {
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			if writeSSE(w, m.ID, event, m.Data) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if w.Flush() != nil {
+				return
+			}
+		}
+	}
+}
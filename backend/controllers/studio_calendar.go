@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+// maxHolidayImportSize caps uploaded ICS files
+const maxHolidayImportSize = 2 << 20
+
+type StudioCalendarController struct {
+	DB *gorm.DB
+}
+
+func NewStudioCalendarController(db *gorm.DB) *StudioCalendarController {
+	return &StudioCalendarController{DB: db}
+}
+
+// --- Operating hours ---
+
+type OperatingDayInput struct {
+	Weekday   int    `json:"weekday" validate:"min=0,max=6"`                // 0 = Sunday
+	OpenTime  string `json:"open_time" validate:"omitempty,datetime=15:04"` // required unless closed
+	CloseTime string `json:"close_time" validate:"omitempty,datetime=15:04"`
+	Closed    bool   `json:"closed"`
+}
+
+type UpdateOperatingHoursInput struct {
+	CourtID *string             `json:"court_id" validate:"omitempty,uuid"` // empty for studio-wide hours
+	Days    []OperatingDayInput `json:"days" validate:"max=7,dive"`
+}
+
+// GetOperatingHours lists studio-wide hours, or a court's own hours
+// GET /api/admin/operating-hours?court_id=
+func (sc *StudioCalendarController) GetOperatingHours(c *fiber.Ctx) error {
+	db := sc.DB.Order("weekday ASC")
+	if courtID := c.Query("court_id"); courtID != "" {
+		db = db.Where("court_id = ?", courtID)
+	} else {
+		db = db.Where("court_id IS NULL")
+	}
+
+	var hours []models.OperatingHours
+	if err := db.Find(&hours).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch operating hours"})
+	}
+
+	return c.JSON(fiber.Map{"data": hours})
+}
+
+// UpdateOperatingHours replaces the week of studio-wide or court hours.
+// Weekdays left out are unrestricted (or follow the studio-wide hours for a court).
+// PUT /api/admin/operating-hours
+func (sc *StudioCalendarController) UpdateOperatingHours(c *fiber.Ctx) error {
+	var input UpdateOperatingHoursInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	if input.CourtID != nil {
+		var court models.Court
+		if err := sc.DB.First(&court, "id = ?", *input.CourtID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Court not found"})
+		}
+	}
+
+	hours := []models.OperatingHours{}
+	seen := map[int]bool{}
+	for _, d := range input.Days {
+		if seen[d.Weekday] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Each weekday can only appear once"})
+		}
+		seen[d.Weekday] = true
+
+		h := models.OperatingHours{CourtID: input.CourtID, Weekday: d.Weekday, Closed: d.Closed}
+		if !d.Closed {
+			if d.OpenTime == "" || d.CloseTime == "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Opening and closing times are required unless the day is closed"})
+			}
+			h.OpenTime, _ = models.ParseTimeOfDay(d.OpenTime)
+			h.CloseTime, _ = models.ParseTimeOfDay(d.CloseTime)
+			if h.CloseTime <= h.OpenTime {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Closing time must be after opening time"})
+			}
+		}
+		hours = append(hours, h)
+	}
+
+	err := sc.DB.Transaction(func(tx *gorm.DB) error {
+		scope := tx.Where("court_id IS NULL")
+		if input.CourtID != nil {
+			scope = tx.Where("court_id = ?", *input.CourtID)
+		}
+		if err := scope.Delete(&models.OperatingHours{}).Error; err != nil {
+			return err
+		}
+		if len(hours) == 0 {
+			return nil
+		}
+		return tx.Create(&hours).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save operating hours"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Operating hours saved", "data": hours})
+}
+
+// --- Holidays ---
+
+type CreateHolidayInput struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name string `json:"name" validate:"required"`
+}
+
+// GetHolidays lists holidays, by default from today on
+// GET /api/admin/holidays?from=YYYY-MM-DD
+func (sc *StudioCalendarController) GetHolidays(c *fiber.Ctx) error {
+	from := c.Query("from", utils.StudioNow().Format("2006-01-02"))
+
+	var holidays []models.Holiday
+	if err := sc.DB.Where("date >= ?", from).Order("date ASC").Find(&holidays).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch holidays"})
+	}
+
+	return c.JSON(fiber.Map{"data": holidays})
+}
+
+// CreateHoliday closes the studio for a day. Schedules that already exist on
+// that day are returned so the admin can close them with a blackout.
+// POST /api/admin/holidays
+func (sc *StudioCalendarController) CreateHoliday(c *fiber.Ctx) error {
+	var input CreateHolidayInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	date, _ := time.Parse("2006-01-02", input.Date)
+	holiday := models.Holiday{Date: date, Name: input.Name, Source: models.HolidaySourceManual}
+
+	result := sc.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&holiday)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create holiday"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A holiday already exists on that date"})
+	}
+
+	existing, err := sc.schedulesOn([]string{input.Date})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check existing schedules"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"data": holiday, "existing_schedules": existing})
+}
+
+// ImportHolidays reads holidays from an ICS file, sent as the multipart
+// field "file" or as the raw request body. Days that already have a holiday
+// are skipped.
+// POST /api/admin/holidays/import
+func (sc *StudioCalendarController) ImportHolidays(c *fiber.Ctx) error {
+	var data []byte
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > maxHolidayImportSize {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "File too large"})
+		}
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Could not read file"})
+		}
+		defer f.Close()
+		if data, err = io.ReadAll(f); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Could not read file"})
+		}
+	} else {
+		data = c.Body()
+		if len(data) > maxHolidayImportSize {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "File too large"})
+		}
+	}
+
+	days, err := services.ParseICalDays(string(data))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	imported := []models.Holiday{}
+	skipped := 0
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		for _, d := range days {
+			name := d.Name
+			if name == "" {
+				name = "Holiday"
+			}
+			holiday := models.Holiday{Date: d.Date, Name: name, Source: models.HolidaySourceICS, ExternalUID: d.UID}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&holiday)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				skipped++
+				continue
+			}
+			imported = append(imported, holiday)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to import holidays"})
+	}
+
+	dates := make([]string, 0, len(imported))
+	for _, h := range imported {
+		dates = append(dates, h.Date.Format("2006-01-02"))
+	}
+	existing, err := sc.schedulesOn(dates)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check existing schedules"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":            "Holidays imported",
+		"imported":           len(imported),
+		"skipped":            skipped,
+		"data":               imported,
+		"existing_schedules": existing,
+	})
+}
+
+// DeleteHoliday removes a holiday
+// DELETE /api/admin/holidays/:id
+func (sc *StudioCalendarController) DeleteHoliday(c *fiber.Ctx) error {
+	result := sc.DB.Delete(&models.Holiday{}, "id = ?", c.Params("id"))
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete holiday"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Holiday not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Holiday deleted"})
+}
+
+// schedulesOn returns the schedules already created on dates
+func (sc *StudioCalendarController) schedulesOn(dates []string) ([]models.Schedule, error) {
+	schedules := []models.Schedule{}
+	if len(dates) == 0 {
+		return schedules, nil
+	}
+	err := sc.DB.Preload("Court").Where("date IN ?", dates).Order("date ASC, start_time ASC").Find(&schedules).Error
+	return schedules, err
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// actorFromContext attributes a change to the authenticated user (and the
+// admin impersonating them, if any)
+func actorFromContext(c *fiber.Ctx, source string) domain.Actor {
+	userID, _ := c.Locals("user_id").(string)
+	impersonatorID, _ := c.Locals("impersonator_id").(string)
+	return domain.Actor{UserID: userID, ImpersonatorID: impersonatorID, Source: source}
+}
+
+// GetReservationTimeline returns the caller's reservation history, oldest first
+// GET /api/reservations/:id/timeline
+func (rc *ReservationController) GetReservationTimeline(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID := c.Locals("user_id").(string)
+
+	var reservation models.Reservation
+	if err := rc.DB.First(&reservation, "id = ?", id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
+	}
+
+	if reservation.UserID != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized"})
+	}
+
+	return rc.timelineResponse(c, &reservation)
+}
+
+// AdminGetReservationTimeline returns any reservation's history
+// GET /api/admin/reservations/:id/timeline
+func (rc *ReservationController) AdminGetReservationTimeline(c *fiber.Ctx) error {
+	var reservation models.Reservation
+	if err := rc.DB.First(&reservation, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reservation not found"})
+	}
+
+	return rc.timelineResponse(c, &reservation)
+}
+
+func (rc *ReservationController) timelineResponse(c *fiber.Ctx, reservation *models.Reservation) error {
+	var timeline []models.ReservationEvent
+	if err := rc.DB.Where("reservation_id = ?", reservation.ID).
+		Order("created_at ASC").
+		Find(&timeline).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch timeline"})
+	}
+
+	data := make([]fiber.Map, 0, len(timeline))
+	for _, e := range timeline {
+		entry := fiber.Map{
+			"id":          e.ID,
+			"type":        e.Type,
+			"from_status": e.FromStatus,
+			"to_status":   e.ToStatus,
+			"actor_id":    e.ActorID,
+			"source":      e.Source,
+			"created_at":  e.CreatedAt,
+		}
+		if e.ImpersonatorID != nil {
+			entry["impersonator_id"] = e.ImpersonatorID
+		}
+		if len(e.Payload) > 0 {
+			entry["payload"] = json.RawMessage(e.Payload)
+		}
+		data = append(data, entry)
+	}
+
+	return c.JSON(fiber.Map{
+		"reservation_id": reservation.ID,
+		"status":         reservation.Status,
+		"data":           data,
+	})
+}
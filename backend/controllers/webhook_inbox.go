@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+)
+
+// processWebhookEvent applies a stored notification to the payment (and through it
+// the reservation) and records the outcome on the inbox entry. The entry is
+// claimed with a row lock first, so a concurrent duplicate waits and then finds
+// it settled; only a replay processes a processed or ignored entry again.
+func (rc *ReservationController) processWebhookEvent(event *models.WebhookEvent, actor domain.Actor, replay bool) error {
+	tx := domain.WithActor(rc.DB.Begin(), actor)
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(event, "id = ?", event.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !replay && webhookSettled(event.Status) {
+		tx.Rollback()
+		return nil
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return rc.finishWebhookEvent(tx, event, models.WebhookFailed, "invalid stored payload", err)
+	}
+
+	newStatus := services.PaymentStatusFromMidtrans(event.TransactionStatus, event.FraudStatus)
+	if newStatus == "" {
+		return rc.finishWebhookEvent(tx, event, models.WebhookIgnored, "no actionable status: "+event.TransactionStatus, nil)
+	}
+	if newStatus == domain.PaymentPartiallyRefunded {
+		// The refunded part was posted when the refund was issued; the
+		// bookings under the order stay paid
+		return rc.finishWebhookEvent(tx, event, models.WebhookProcessed, "partial refund, payments stay settled", nil)
+	}
+
+	// A savepoint lets a failed payment update be undone while the claim on
+	// the inbox entry is kept for recording the outcome
+	tx.SavePoint("payments")
+	rollback := func() { tx.RollbackTo("payments") }
+
+	// A cart booking has one payment per reservation under a shared order ID;
+	// all of them follow the notification together
+	var payments []models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("midtrans_order_id = ? OR gateway_order_id = ?", event.OrderID, event.OrderID).
+		Order("id ASC").
+		Find(&payments).Error; err != nil || len(payments) == 0 {
+		rollback()
+		if err == nil {
+			err = gorm.ErrRecordNotFound
+		}
+		return rc.finishWebhookEvent(tx, event, models.WebhookFailed, "payment not found", err)
+	}
+
+	for i := range payments {
+		payment := &payments[i]
+		if payment.Status != newStatus && !domain.CanTransitionPayment(payment.Status, newStatus) {
+			rollback()
+			return rc.finishWebhookEvent(tx, event, models.WebhookIgnored,
+				fmt.Sprintf("illegal transition %s -> %s", payment.Status, newStatus), nil)
+		}
+
+		if paymentType, _ := payload["payment_type"].(string); paymentType != "" {
+			payment.PaymentMethod = paymentType
+		}
+		if transactionTimeStr, _ := payload["transaction_time"].(string); transactionTimeStr != "" {
+			if t, err := time.Parse("2006-01-02 15:04:05", transactionTimeStr); err == nil {
+				payment.TransactionTime = &t
+			}
+		}
+		payment.MidtransResponse = event.Payload
+
+		if err := domain.ApplyPaymentStatus(tx, payment, newStatus); err != nil {
+			rollback()
+			if errors.Is(err, domain.ErrIllegalTransition) || errors.Is(err, domain.ErrGuardFailed) {
+				return rc.finishWebhookEvent(tx, event, models.WebhookIgnored, err.Error(), nil)
+			}
+			return rc.finishWebhookEvent(tx, event, models.WebhookFailed, "failed to apply payment status", err)
+		}
+	}
+
+	return rc.finishWebhookEvent(tx, event, models.WebhookProcessed, "payment "+newStatus, nil)
+}
+
+// webhookSettled reports whether an inbox entry needs no further processing
+func webhookSettled(status string) bool {
+	return status == models.WebhookProcessed || status == models.WebhookIgnored
+}
+
+// finishWebhookEvent stores the processing outcome on the claimed entry,
+// commits tx and passes cause through
+func (rc *ReservationController) finishWebhookEvent(tx *gorm.DB, event *models.WebhookEvent, status, note string, cause error) error {
+	now := time.Now()
+	event.Status = status
+	event.Note = note
+	event.Attempts++
+	event.ProcessedAt = &now
+
+	if cause != nil {
+		event.Note = note + ": " + cause.Error()
+		log.Printf("Webhook event %s (order %s): %s", event.ID, event.OrderID, event.Note)
+	}
+
+	if err := tx.Model(event).Updates(map[string]interface{}{
+		"status":       event.Status,
+		"note":         event.Note,
+		"attempts":     event.Attempts,
+		"processed_at": event.ProcessedAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to update webhook event %s: %v", event.ID, err)
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Failed to commit webhook event %s: %v", event.ID, err)
+		return err
+	}
+
+	return cause
+}
+
+// --- Admin Endpoints ---
+
+// GetWebhookEvents lists inbox entries, newest first
+func (rc *ReservationController) GetWebhookEvents(c *fiber.Ctx) error {
+	db := rc.DB.Order("created_at DESC").Limit(200)
+
+	if status := c.Query("status"); status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		db = db.Where("order_id = ?", orderID)
+	}
+
+	var events []models.WebhookEvent
+	if err := db.Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch webhook events"})
+	}
+
+	return c.JSON(fiber.Map{"data": events})
+}
+
+// GetWebhookEvent returns a single inbox entry including its raw payload
+func (rc *ReservationController) GetWebhookEvent(c *fiber.Ctx) error {
+	var event models.WebhookEvent
+	if err := rc.DB.First(&event, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook event not found"})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    event,
+		"payload": json.RawMessage(event.Payload),
+	})
+}
+
+// ReplayWebhookEvent reprocesses a stored notification. The state machine makes
+// replaying an already applied notification a no-op.
+func (rc *ReservationController) ReplayWebhookEvent(c *fiber.Ctx) error {
+	var event models.WebhookEvent
+	if err := rc.DB.First(&event, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook event not found"})
+	}
+
+	err := rc.processWebhookEvent(&event, actorFromContext(c, domain.SourceWebhookReplay), true)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Replay failed", "data": event})
+	}
+
+	return c.JSON(fiber.Map{"message": "Webhook event replayed", "data": event})
+}
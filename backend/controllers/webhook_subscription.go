@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+type WebhookSubscriptionController struct {
+	DB     *gorm.DB
+	Sender *services.WebhookSender
+}
+
+func NewWebhookSubscriptionController(db *gorm.DB, sender *services.WebhookSender) *WebhookSubscriptionController {
+	return &WebhookSubscriptionController{DB: db, Sender: sender}
+}
+
+type WebhookSubscriptionInput struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types" validate:"required,min=1"`
+	Secret      string   `json:"secret" validate:"omitempty,min=16"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+func webhookSubscriptionResponse(s *models.WebhookSubscription) fiber.Map {
+	return fiber.Map{
+		"id":          s.ID,
+		"url":         s.URL,
+		"description": s.Description,
+		"event_types": s.EventTypeList(),
+		"is_active":   s.IsActive,
+		"created_at":  s.CreatedAt,
+		"updated_at":  s.UpdatedAt,
+	}
+}
+
+func validateEventTypes(types []string) string {
+	for _, t := range types {
+		known := false
+		for _, e := range services.OutboundWebhookEvents {
+			if e == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return t
+		}
+	}
+	return ""
+}
+
+// GetSubscriptions lists outbound webhook subscriptions
+func (wc *WebhookSubscriptionController) GetSubscriptions(c *fiber.Ctx) error {
+	var subs []models.WebhookSubscription
+	if err := wc.DB.Order("created_at DESC").Find(&subs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch subscriptions"})
+	}
+
+	result := []fiber.Map{}
+	for i := range subs {
+		result = append(result, webhookSubscriptionResponse(&subs[i]))
+	}
+
+	return c.JSON(fiber.Map{"data": result, "available_events": services.OutboundWebhookEvents})
+}
+
+// CreateSubscription registers an endpoint. The signing secret is generated when not
+// provided and only returned in this response.
+func (wc *WebhookSubscriptionController) CreateSubscription(c *fiber.Ctx) error {
+	var input WebhookSubscriptionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	if unknown := validateEventTypes(input.EventTypes); unknown != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown event type: " + unknown})
+	}
+
+	if input.Secret == "" {
+		secret, err := services.RandomURLSafe(32)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate secret"})
+		}
+		input.Secret = "whsec_" + secret
+	}
+
+	sub := models.WebhookSubscription{
+		URL:         input.URL,
+		Description: input.Description,
+		EventTypes:  strings.Join(input.EventTypes, ","),
+		Secret:      input.Secret,
+		IsActive:    input.IsActive == nil || *input.IsActive,
+	}
+
+	if err := wc.DB.Create(&sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create subscription"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Subscription created. Store the secret now, it will not be shown again.",
+		"secret":  sub.Secret,
+		"data":    webhookSubscriptionResponse(&sub),
+	})
+}
+
+// UpdateSubscription edits URL, events and active flag; a new secret rotates signing
+func (wc *WebhookSubscriptionController) UpdateSubscription(c *fiber.Ctx) error {
+	var sub models.WebhookSubscription
+	if err := wc.DB.First(&sub, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Subscription not found"})
+	}
+
+	var input WebhookSubscriptionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if errors := utils.ValidateStruct(input); errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+	}
+
+	if unknown := validateEventTypes(input.EventTypes); unknown != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown event type: " + unknown})
+	}
+
+	sub.URL = input.URL
+	sub.Description = input.Description
+	sub.EventTypes = strings.Join(input.EventTypes, ",")
+	if input.Secret != "" {
+		sub.Secret = input.Secret
+	}
+	if input.IsActive != nil {
+		sub.IsActive = *input.IsActive
+	}
+
+	if err := wc.DB.Save(&sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update subscription"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Subscription updated", "data": webhookSubscriptionResponse(&sub)})
+}
+
+// DeleteSubscription removes the endpoint and its delivery log
+func (wc *WebhookSubscriptionController) DeleteSubscription(c *fiber.Ctx) error {
+	if err := wc.DB.Delete(&models.WebhookSubscription{}, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete subscription"})
+	}
+	return c.JSON(fiber.Map{"message": "Subscription deleted"})
+}
+
+// GetDeliveries returns the delivery log of a subscription
+func (wc *WebhookSubscriptionController) GetDeliveries(c *fiber.Ctx) error {
+	db := wc.DB.Where("subscription_id = ?", c.Params("id")).Order("created_at DESC").Limit(200)
+
+	if status := c.Query("status"); status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := db.Find(&deliveries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch deliveries"})
+	}
+
+	return c.JSON(fiber.Map{"data": deliveries})
+}
+
+// GetDeliveryAttempts lists every attempt of a delivery, newest first
+func (wc *WebhookSubscriptionController) GetDeliveryAttempts(c *fiber.Ctx) error {
+	var attempts []models.WebhookDeliveryAttempt
+	if err := wc.DB.
+		Joins("JOIN webhook_deliveries ON webhook_deliveries.id = webhook_delivery_attempts.delivery_id").
+		Where("webhook_delivery_attempts.delivery_id = ? AND webhook_deliveries.subscription_id = ?", c.Params("deliveryId"), c.Params("id")).
+		Order("webhook_delivery_attempts.attempt DESC").
+		Find(&attempts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch attempts"})
+	}
+
+	return c.JSON(fiber.Map{"data": attempts})
+}
+
+// Redeliver queues a delivery again regardless of its current status. Past
+// attempts are kept; the delivery gets a fresh round of retries.
+func (wc *WebhookSubscriptionController) Redeliver(c *fiber.Ctx) error {
+	var delivery models.WebhookDelivery
+	if err := wc.DB.First(&delivery, "id = ? AND subscription_id = ?", c.Params("deliveryId"), c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Delivery not found"})
+	}
+
+	if err := wc.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status":          models.DeliveryPending,
+		"retry_base":      delivery.Attempts,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to queue redelivery"})
+	}
+
+	wc.Sender.Wake()
+
+	return c.JSON(fiber.Map{"message": "Redelivery queued", "data": delivery})
+}
@@ -1,5 +1,6 @@
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS btree_gist; -- untuk constraint anti-overlap jadwal
 
 -- ====================
 -- Users Table (untuk manual JWT auth)
@@ -10,6 +11,13 @@ CREATE TABLE users (
     email TEXT UNIQUE NOT NULL,
     email_verified TIMESTAMPTZ, -- NULL jika belum verify (opsional, jika Anda implement verify)
     image TEXT, -- Profile picture URL (opsional)
+    phone TEXT,
+    phone_verified_at TIMESTAMPTZ, -- diisi setelah OTP WhatsApp berhasil
+    locale TEXT NOT NULL DEFAULT 'id', -- bahasa notifikasi: id, en
+    calendar_token_hash TEXT UNIQUE, -- sha256 token feed kalender ICS pribadi
+    emergency_contact_name TEXT,
+    emergency_contact_phone TEXT,
+    anonymized_at TIMESTAMPTZ, -- diisi saat akun dihapus (PII dianonimkan)
     password_hash TEXT NOT NULL, -- bcrypt hash untuk credentials
     role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
     created_at TIMESTAMPTZ DEFAULT NOW(),
@@ -37,21 +45,42 @@ CREATE TABLE schedules (
     start_time TIME NOT NULL,
     end_time TIME NOT NULL,
     is_available BOOLEAN DEFAULT TRUE,
+    is_private BOOLEAN DEFAULT FALSE, -- sesi privat dari session request, tidak bisa dibooking publik
+    price DECIMAL(10,2), -- NULL = pakai price_per_slot court
+    capacity INTEGER CHECK (capacity > 0), -- NULL = pakai capacity court
+    released_at TIMESTAMPTZ, -- sesi privat yang reservasinya berakhir; court bebas lagi
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
-    UNIQUE (court_id, date, start_time)
+    -- slot di court yang sama tidak boleh tumpang tindih (butuh btree_gist);
+    -- deferrable agar geser jadwal massal dicek di akhir statement
+    CONSTRAINT schedules_no_overlap EXCLUDE USING gist (
+        court_id WITH =,
+        tsrange(date + start_time, date + end_time) WITH &&
+    ) WHERE (released_at IS NULL) DEFERRABLE INITIALLY IMMEDIATE
 );
 CREATE INDEX idx_schedules_court_date ON schedules(court_id, date);
 
+-- bookings: satu checkout keranjang (beberapa slot/kursi, satu transaksi Snap)
+CREATE TABLE bookings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    total_amount DECIMAL(10,2) NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 CREATE TABLE reservations (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Link ke users (UUID sekarang)
     court_id UUID NOT NULL REFERENCES courts(id) ON DELETE CASCADE,
     schedule_id UUID NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+    booking_id UUID REFERENCES bookings(id), -- NULL untuk booking satu slot
+    seats INT NOT NULL DEFAULT 1 CHECK (seats > 0),
     status TEXT NOT NULL DEFAULT 'pending'
         CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled', 'refunded')),
     total_amount DECIMAL(10,2) NOT NULL,
     notes TEXT,
+    checked_in_at TIMESTAMPTZ, -- diisi admin saat peserta hadir di studio
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
     UNIQUE (schedule_id) -- Cegah double booking
@@ -62,7 +91,9 @@ CREATE TABLE payments (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     reservation_id UUID NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
     midtrans_order_id TEXT UNIQUE NOT NULL,
+    gateway_order_id TEXT, -- order Snap bersama untuk booking keranjang (= bookings.id)
     amount DECIMAL(10,2) NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'booking', -- 'booking' atau 'adjustment' (selisih reschedule)
     status TEXT NOT NULL DEFAULT 'pending'
         CHECK (status IN ('pending', 'success', 'failed', 'refunded')),
     payment_method TEXT,
@@ -77,47 +108,433 @@ CREATE TABLE payments (
 CREATE TABLE audit_logs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     user_id UUID REFERENCES users(id),
+    impersonator_id UUID REFERENCES users(id), -- admin asli saat impersonation
     action TEXT NOT NULL,
-    table_name TEXT NOT NULL,
-    record_id UUID NOT NULL,
+    table_name TEXT,
+    record_id TEXT,
     old_data JSONB,
     new_data JSONB,
+    ip_address TEXT,
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
 -- ====================
--- Triggers
+-- Status transitions
 -- ====================
--- Update schedule availability berdasarkan reservation status
-CREATE OR REPLACE FUNCTION update_schedule_availability()
-RETURNS TRIGGER AS $$
-BEGIN
-    IF NEW.status IN ('confirmed', 'paid') THEN
-        UPDATE schedules SET is_available = FALSE WHERE id = NEW.schedule_id;
-    ELSIF NEW.status = 'cancelled' THEN
-        UPDATE schedules SET is_available = TRUE WHERE id = NEW.schedule_id;
-    END IF;
-    RETURN NEW;
-END;
-$$ LANGUAGE plpgsql;
+-- Perubahan status reservation/payment (termasuk release seat) ditangani oleh
+-- package domain di backend, bukan trigger, supaya semua jalur melewati state
+-- machine yang sama.
+
+-- ledger_entries: posting akuntansi saat pembayaran settle / refund
+CREATE TABLE ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reservation_id UUID NOT NULL REFERENCES reservations(id),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    type TEXT NOT NULL CHECK (type IN ('charge', 'refund')),
+    amount DECIMAL(10,2) NOT NULL, -- negatif untuk refund
+    method TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_ledger_entries_reservation ON ledger_entries(reservation_id);
+
+-- ====================
+-- Admin Impersonation
+-- ====================
+-- user_permissions: permission tambahan per user di luar default role
+CREATE TABLE user_permissions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, permission)
+);
+
+-- ====================
+-- API Keys (kiosk & partner)
+-- ====================
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL, -- ditampilkan di UI untuk identifikasi
+    key_hash TEXT UNIQUE NOT NULL, -- SHA-256, plaintext tidak disimpan
+    scopes TEXT NOT NULL, -- comma separated, e.g. 'schedules:read,reservations:write'
+    rate_limit_per_minute INTEGER DEFAULT 60,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_by_id UUID NOT NULL REFERENCES users(id),
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- ====================
+-- Social Login (OpenID Connect)
+-- ====================
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL, -- nama provider di OIDC_PROVIDERS, e.g. 'google'
+    subject TEXT NOT NULL, -- claim 'sub' dari id_token
+    email TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (provider, subject)
+);
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- ====================
+-- Idempotency Keys (booking & payment endpoints)
+-- ====================
+CREATE TABLE idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    key TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    request_hash TEXT NOT NULL, -- SHA-256 dari method + path + body
+    status_code INTEGER, -- 0/NULL selama request pertama masih diproses
+    content_type TEXT,
+    response_body BYTEA,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, key)
+);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+-- ====================
+-- Webhook Inbox (Midtrans notifications)
+-- ====================
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    source TEXT NOT NULL DEFAULT 'midtrans',
+    dedup_key TEXT UNIQUE NOT NULL, -- hash dari order_id, transaction_id, status
+    order_id TEXT NOT NULL,
+    transaction_id TEXT,
+    transaction_status TEXT,
+    fraud_status TEXT,
+    payload JSONB,
+    status TEXT NOT NULL DEFAULT 'received'
+        CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+    note TEXT,
+    attempts INTEGER DEFAULT 0,
+    processed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_webhook_events_order ON webhook_events(order_id);
+
+-- ====================
+-- Transactional Outbox (domain events)
+-- ====================
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type TEXT NOT NULL, -- ReservationCreated, ReservationPaid, ...
+    aggregate_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    dispatched_at TIMESTAMPTZ,
+    failed_at TIMESTAMPTZ, -- menyerah setelah max attempts
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE dispatched_at IS NULL AND failed_at IS NULL;
+
+-- Subscriber yang sudah berhasil menangani event; retry hanya menjalankan sisanya
+CREATE TABLE outbox_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    subscriber TEXT NOT NULL, -- nama dari Dispatcher.Subscribe
+    delivered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (event_id, subscriber)
+);
+
+-- ====================
+-- Outbound Webhooks
+-- ====================
+CREATE TABLE webhook_subscriptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url TEXT NOT NULL,
+    description TEXT,
+    event_types TEXT NOT NULL, -- comma separated, e.g. 'ReservationPaid,ReservationCancelled'
+    secret TEXT NOT NULL, -- untuk signature HMAC-SHA256
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id TEXT NOT NULL, -- outbox_events.id
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER DEFAULT 0, -- total, tidak pernah di-reset
+    retry_base INTEGER DEFAULT 0, -- nilai attempts saat redeliver terakhir
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    response_status INTEGER,
+    response_body TEXT,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (subscription_id, event_id)
+);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- Satu baris per percobaan kirim, untuk riwayat lengkap
+CREATE TABLE webhook_delivery_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    response_status INTEGER,
+    response_body TEXT,
+    error TEXT,
+    duration_ms BIGINT,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id);
+
+-- ====================
+-- Reservation Rescheduling
+-- ====================
+CREATE TABLE reservation_reschedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reservation_id UUID NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+    from_schedule_id UUID NOT NULL REFERENCES schedules(id),
+    to_schedule_id UUID NOT NULL REFERENCES schedules(id),
+    price_difference DECIMAL(10,2) NOT NULL DEFAULT 0, -- positif = tambah bayar, negatif = refund
+    adjustment_payment_id UUID REFERENCES payments(id),
+    refund_status TEXT, -- '', required, refunded, failed, manual
+    actor_id UUID NOT NULL REFERENCES users(id),
+    reverted_at TIMESTAMPTZ, -- diisi jika pembayaran selisih gagal/kedaluwarsa dan perpindahan dibatalkan
+    needs_review_at TIMESTAMPTZ, -- diisi jika perpindahan tidak bisa dibatalkan (slot lama penuh, dsb.)
+    review_note TEXT,
+    reviewed_at TIMESTAMPTZ, -- diisi admin setelah ditangani
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_reservation_reschedules_reservation ON reservation_reschedules(reservation_id);
+CREATE INDEX idx_reservation_reschedules_review ON reservation_reschedules(created_at)
+    WHERE (needs_review_at IS NOT NULL AND reviewed_at IS NULL) OR refund_status IN ('failed', 'required');
 
-CREATE TRIGGER trig_reservation_status_change
-AFTER INSERT OR UPDATE OF status ON reservations
-FOR EACH ROW EXECUTE FUNCTION update_schedule_availability();
+-- ====================
+-- Reservation Timeline
+-- ====================
+-- Append-only: setiap transisi reservasi & pembayaran dicatat beserta aktor dan jalurnya.
+-- Riwayat ikut terhapus bila reservasinya dihapus; aktor yang dihapus menjadi NULL.
+CREATE TABLE reservation_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reservation_id UUID NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+    type TEXT NOT NULL, -- created, status_changed, payment_status_changed, payment_partially_refunded, rescheduled
+    from_status TEXT,
+    to_status TEXT,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL untuk webhook/sistem
+    impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    source TEXT NOT NULL, -- user, admin, webhook, webhook_replay, mark_paid, proactive_check, manual_booking, account_deletion, system
+    payload JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_reservation_events_reservation ON reservation_events(reservation_id, created_at);
 
--- Update reservation status dari payment (via trigger)
-CREATE OR REPLACE FUNCTION update_reservation_on_payment()
-RETURNS TRIGGER AS $$
+-- Tolak UPDATE dan DELETE dengan error agar riwayat tidak bisa diubah.
+-- Perubahan dari aksi foreign key (cascade / set null) berjalan di dalam trigger lain
+-- (pg_trigger_depth() > 1) sehingga tetap diizinkan.
+-- Dipasang juga oleh backend saat start (domain.EnsureTimelineAppendOnly).
+CREATE OR REPLACE FUNCTION reservation_events_append_only() RETURNS trigger AS $$
 BEGIN
-    IF NEW.status = 'success' THEN
-        UPDATE reservations SET status = 'paid' WHERE id = NEW.reservation_id;
-    ELSIF NEW.status IN ('failed', 'refunded') THEN
-        UPDATE reservations SET status = 'cancelled' WHERE id = NEW.reservation_id;
+    IF pg_trigger_depth() > 1 THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
     END IF;
-    RETURN NEW;
+    RAISE EXCEPTION 'reservation_events is append-only (% rejected)', TG_OP
+        USING ERRCODE = 'restrict_violation';
 END;
 $$ LANGUAGE plpgsql;
 
-CREATE TRIGGER trig_payment_status_change
-AFTER UPDATE OF status ON payments
-FOR EACH ROW EXECUTE FUNCTION update_reservation_on_payment();
\ No newline at end of file
+CREATE TRIGGER reservation_events_append_only
+    BEFORE UPDATE OR DELETE ON reservation_events
+    FOR EACH ROW EXECUTE FUNCTION reservation_events_append_only();
+
+-- ====================
+-- Reservation Attendees
+-- ====================
+-- Nama peserta per kursi; tamu bisa klaim kursinya lewat link email
+CREATE TABLE reservation_attendees (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reservation_id UUID NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    phone TEXT,
+    waiver_status TEXT NOT NULL DEFAULT 'pending' CHECK (waiver_status IN ('pending', 'signed')),
+    waiver_signed_at TIMESTAMPTZ,
+    claim_token_hash TEXT, -- sha256 dari token di link klaim
+    claim_token_expires_at TIMESTAMPTZ,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL, -- terisi setelah diklaim
+    claimed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_reservation_attendees_reservation ON reservation_attendees(reservation_id);
+CREATE INDEX idx_reservation_attendees_claim ON reservation_attendees(claim_token_hash);
+
+-- ====================
+-- Private Session Requests
+-- ====================
+-- Permintaan sesi privat di luar jadwal; disetujui admin -> schedule privat + reservasi pending
+CREATE TABLE session_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    court_id UUID REFERENCES courts(id),
+    preferred_instructor TEXT,
+    class_type TEXT NOT NULL,
+    date DATE NOT NULL,
+    start_time TIME NOT NULL, -- waktu lokal studio
+    end_time TIME NOT NULL,
+    notes TEXT,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'declined', 'expired', 'cancelled')),
+    decline_reason TEXT,
+    schedule_id UUID REFERENCES schedules(id),
+    reservation_id UUID REFERENCES reservations(id),
+    snap_token TEXT,
+    payment_redirect_url TEXT,
+    reviewed_by UUID REFERENCES users(id),
+    reviewed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL, -- otomatis 'expired' jika belum direspon
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_session_requests_status ON session_requests(status, expires_at);
+
+-- ====================
+-- Notifications
+-- ====================
+-- Preferensi channel per user (tanpa baris = default channel)
+CREATE TABLE notification_preferences (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel TEXT NOT NULL, -- email, whatsapp
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, channel)
+);
+
+-- Log pengiriman; dedup_key unik agar event yang di-retry tidak mengirim ulang
+CREATE TABLE notification_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dedup_key TEXT UNIQUE NOT NULL, -- <event id>:<channel>
+    template TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    locale TEXT,
+    recipient TEXT,
+    subject TEXT,
+    body TEXT,
+    status TEXT NOT NULL, -- sent, failed, skipped
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_notification_deliveries_user ON notification_deliveries(user_id, created_at);
+
+-- ====================
+-- Class Reminders
+-- ====================
+-- Antrian pengingat per reservasi/schedule/offset (REMINDER_OFFSETS, default 24h,2h)
+CREATE TABLE class_reminders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reservation_id UUID NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+    schedule_id UUID NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+    offset_minutes INTEGER NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    send_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued', -- queued, sent, cancelled, failed
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (reservation_id, schedule_id, offset_minutes) -- cegah pengingat ganda setelah restart
+);
+CREATE INDEX idx_class_reminders_due ON class_reminders(status, send_at);
+
+-- ====================
+-- WhatsApp Messaging
+-- ====================
+-- Kode OTP untuk verifikasi nomor HP sebelum notifikasi WhatsApp bisa diaktifkan
+CREATE TABLE phone_verifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone TEXT NOT NULL, -- format E.164, e.g. +628123456789
+    code_hash TEXT NOT NULL,
+    attempts INTEGER DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    verified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_phone_verifications_user ON phone_verifications(user_id, created_at);
+
+-- ====================
+-- In-App Notifications
+-- ====================
+-- Inbox per user (ikon lonceng di frontend)
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL, -- payment_confirmed, class_cancelled, waitlist_offer
+    title TEXT NOT NULL,
+    body TEXT,
+    data JSONB, -- e.g. reservation_id untuk link di frontend
+    dedup_key TEXT UNIQUE, -- id event outbox, cegah duplikat saat retry
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_notifications_user_created ON notifications(user_id, created_at);
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+-- ====================
+-- Jam Operasional, Hari Libur & Blackout
+-- ====================
+-- Jam buka per hari (0 = Minggu). court_id NULL berlaku untuk seluruh studio;
+-- baris milik court menimpa baris studio untuk hari yang sama
+CREATE TABLE operating_hours (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    court_id UUID REFERENCES courts(id) ON DELETE CASCADE,
+    weekday INTEGER NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    open_time TIME NOT NULL,
+    close_time TIME NOT NULL,
+    closed BOOLEAN NOT NULL DEFAULT FALSE, -- tutup seharian
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_operating_hours_scope
+    ON operating_hours(COALESCE(court_id, '00000000-0000-0000-0000-000000000000'::uuid), weekday);
+
+-- Hari libur studio (manual atau impor ICS); tidak ada jadwal yang dibuat di hari ini
+CREATE TABLE holidays (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    date DATE NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'ics')),
+    external_uid TEXT, -- UID event dari file ICS
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Penutupan sementara (mis. maintenance); court_id NULL = seluruh studio
+CREATE TABLE blackouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    court_id UUID REFERENCES courts(id) ON DELETE CASCADE,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL CHECK (ends_at > starts_at),
+    reason TEXT,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_blackouts_period ON blackouts(starts_at, ends_at);
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrIllegalTransition is matched by every *TransitionError
+	ErrIllegalTransition = errors.New("illegal status transition")
+	// ErrGuardFailed is matched by every *GuardError
+	ErrGuardFailed = errors.New("transition guard failed")
+)
+
+// TransitionError reports a status change that is not in the state machine
+type TransitionError struct {
+	Entity string
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot go from %s to %s", e.Entity, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// GuardError reports a legal transition whose precondition is not met
+type GuardError struct {
+	Entity string
+	From   string
+	To     string
+	Reason string
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("%s cannot go from %s to %s: %s", e.Entity, e.From, e.To, e.Reason)
+}
+
+func (e *GuardError) Is(target error) bool {
+	return target == ErrGuardFailed
+}
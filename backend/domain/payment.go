@@ -0,0 +1,197 @@
+package domain
+
+import (
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// Payment statuses
+const (
+	PaymentPending  = "pending"
+	PaymentSuccess  = "success"
+	PaymentFailed   = "failed"
+	PaymentRefunded = "refunded"
+)
+
+// PaymentPartiallyRefunded is the gateway status of an order part of which was
+// refunded. It is never stored: the payment stays settled and the refunded
+// part is posted to the ledger when the refund is issued.
+const PaymentPartiallyRefunded = "partial_refund"
+
+// paymentTransitions is the payment state machine. Payments only move
+// forward, so an out-of-order notification cannot regress success to pending.
+var paymentTransitions = map[string][]string{
+	PaymentPending: {PaymentSuccess, PaymentFailed},
+	PaymentSuccess: {PaymentRefunded},
+}
+
+// reservationStatusForPayment is the reservation status a payment status implies
+var reservationStatusForPayment = map[string]string{
+	PaymentSuccess:  ReservationPaid,
+	PaymentFailed:   ReservationCancelled,
+	PaymentRefunded: ReservationRefunded,
+}
+
+func CanTransitionPayment(from, to string) bool {
+	for _, allowed := range paymentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPaymentStatus saves p with status to inside tx, posts the ledger entry
+// and moves the reservation to the matching status. Re-applying the current
+// status is a no-op apart from saving the other fields of p, so callers can
+// record updated gateway details.
+func ApplyPaymentStatus(tx *gorm.DB, p *models.Payment, to string) error {
+	from := p.Status
+	if from == to {
+		return tx.Save(p).Error
+	}
+
+	if !CanTransitionPayment(from, to) {
+		return &TransitionError{Entity: "payment", From: from, To: to}
+	}
+
+	p.Status = to
+	result := tx.Model(p).Where("status = ?", from).Select("*").Updates(p)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		p.Status = from
+		return &TransitionError{Entity: "payment", From: from, To: to}
+	}
+
+	amount := p.Amount
+	if to == PaymentRefunded {
+		// Earlier partial refunds (e.g. a move to a cheaper slot) are already
+		// on the ledger; only the remainder goes back now
+		var err error
+		if amount, err = RefundableAmount(tx, p); err != nil {
+			return err
+		}
+	}
+
+	if err := RecordReservationEvent(tx, p.ReservationID, TimelinePaymentStatusChanged, from, to, paymentTimelinePayload(p, amount)); err != nil {
+		return err
+	}
+
+	switch to {
+	case PaymentSuccess:
+		if err := postLedger(tx, p, "charge", amount); err != nil {
+			return err
+		}
+	case PaymentRefunded:
+		if err := postLedger(tx, p, "refund", -amount); err != nil {
+			return err
+		}
+		if err := events.Publish(tx, events.PaymentRefunded, p.ID, events.PaymentPayload{
+			PaymentID:     p.ID,
+			ReservationID: p.ReservationID,
+			OrderID:       p.MidtransOrderID,
+			Amount:        amount,
+			Status:        p.Status,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Adjustment payments never change the reservation status, but a top-up
+	// that fails or expires undoes the move it was paying for
+	if !IsBookingPayment(p) {
+		if to == PaymentFailed {
+			return revertReschedule(tx, p)
+		}
+		return nil
+	}
+
+	var reservation models.Reservation
+	if err := tx.First(&reservation, "id = ?", p.ReservationID).Error; err != nil {
+		return err
+	}
+
+	target := reservationStatusForPayment[to]
+	if target == "" || reservation.Status == target {
+		return nil
+	}
+
+	if err := TransitionReservation(tx, &reservation, target); err != nil {
+		// Money moved for a reservation that can no longer follow (e.g. the
+		// user cancelled before paying). Keep the payment and flag it.
+		if errors.Is(err, ErrIllegalTransition) {
+			log.Printf("Payment %s is %s but reservation %s is %s: needs manual review", p.ID, to, reservation.ID, reservation.Status)
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// IsBookingPayment reports whether p pays for the reservation itself
+func IsBookingPayment(p *models.Payment) bool {
+	return p.Kind == "" || p.Kind == models.PaymentKindBooking
+}
+
+// RecordPartialRefund posts a refund of amount against a settled payment
+// without changing its status (e.g. moving to a cheaper slot)
+func RecordPartialRefund(tx *gorm.DB, p *models.Payment, amount float64) error {
+	if p.Status != PaymentSuccess {
+		return &TransitionError{Entity: "payment", From: p.Status, To: "partially refunded"}
+	}
+
+	if err := postLedger(tx, p, "refund", -amount); err != nil {
+		return err
+	}
+
+	if err := RecordReservationEvent(tx, p.ReservationID, TimelinePartialRefund, p.Status, p.Status, paymentTimelinePayload(p, amount)); err != nil {
+		return err
+	}
+
+	return events.Publish(tx, events.PaymentRefunded, p.ID, events.PaymentPayload{
+		PaymentID:     p.ID,
+		ReservationID: p.ReservationID,
+		OrderID:       p.MidtransOrderID,
+		Amount:        amount,
+		Status:        p.Status,
+	})
+}
+
+// RefundableAmount is what is left of p after the refunds already posted
+// against it
+func RefundableAmount(tx *gorm.DB, p *models.Payment) (float64, error) {
+	var refunded float64
+	err := tx.Model(&models.LedgerEntry{}).
+		Where("payment_id = ? AND type = ?", p.ID, "refund").
+		Select("COALESCE(-SUM(amount), 0)").
+		Scan(&refunded).Error
+	return p.Amount - refunded, err
+}
+
+func postLedger(tx *gorm.DB, p *models.Payment, entryType string, amount float64) error {
+	return tx.Create(&models.LedgerEntry{
+		ReservationID: p.ReservationID,
+		PaymentID:     p.ID,
+		Type:          entryType,
+		Amount:        amount,
+		Method:        p.PaymentMethod,
+	}).Error
+}
+
+func paymentTimelinePayload(p *models.Payment, amount float64) map[string]interface{} {
+	return map[string]interface{}{
+		"payment_id":     p.ID,
+		"order_id":       p.MidtransOrderID,
+		"kind":           p.Kind,
+		"amount":         amount,
+		"payment_method": p.PaymentMethod,
+	}
+}
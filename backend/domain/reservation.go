@@ -0,0 +1,379 @@
+package domain
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// Reservation statuses
+const (
+	ReservationPending   = "pending"
+	ReservationConfirmed = "confirmed"
+	ReservationPaid      = "paid"
+	ReservationCancelled = "cancelled"
+	ReservationRefunded  = "refunded"
+)
+
+// ActiveReservationStatuses are the statuses that hold a seat
+var ActiveReservationStatuses = []string{ReservationPending, ReservationPaid, ReservationConfirmed}
+
+type reservationTransition struct {
+	// guard returns a reason when the transition must not happen yet
+	guard func(tx *gorm.DB, r *models.Reservation) (string, error)
+}
+
+// reservationTransitions is the reservation state machine: from -> to -> guard.
+// cancelled and refunded are terminal. paid and refunded follow the booking
+// payment, so they are guarded on its status; ApplyPaymentStatus saves the
+// payment before moving the reservation.
+var reservationTransitions = map[string]map[string]reservationTransition{
+	ReservationPending: {
+		ReservationConfirmed: {},
+		ReservationPaid:      {guard: requireBookingPayment(PaymentSuccess)},
+		ReservationCancelled: {},
+	},
+	ReservationConfirmed: {
+		ReservationPaid:      {guard: requireBookingPayment(PaymentSuccess)},
+		ReservationCancelled: {},
+	},
+	ReservationPaid: {
+		ReservationCancelled: {},
+		ReservationRefunded:  {guard: requireBookingPayment(PaymentRefunded)},
+	},
+}
+
+// requireBookingPayment passes when the reservation has a booking payment in
+// status. Adjustment payments (reschedule top-ups) never count.
+func requireBookingPayment(status string) func(tx *gorm.DB, r *models.Reservation) (string, error) {
+	return func(tx *gorm.DB, r *models.Reservation) (string, error) {
+		var count int64
+		if err := tx.Model(&models.Payment{}).
+			Where("reservation_id = ? AND kind = ? AND status = ?", r.ID, models.PaymentKindBooking, status).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "no booking payment is " + status, nil
+		}
+		return "", nil
+	}
+}
+
+// HoldsSeat reports whether a reservation in status counts against capacity
+func HoldsSeat(status string) bool {
+	for _, s := range ActiveReservationStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func CanTransitionReservation(from, to string) bool {
+	_, ok := reservationTransitions[from][to]
+	return ok
+}
+
+// TransitionReservation moves r to status to inside tx. It checks the state
+// machine and guard, writes the status only if the row still has the status
+// r was loaded with, and releases the seat when the reservation stops
+// holding one.
+func TransitionReservation(tx *gorm.DB, r *models.Reservation, to string) error {
+	from := r.Status
+
+	t, ok := reservationTransitions[from][to]
+	if !ok {
+		return &TransitionError{Entity: "reservation", From: from, To: to}
+	}
+
+	if t.guard != nil {
+		reason, err := t.guard(tx, r)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return &GuardError{Entity: "reservation", From: from, To: to, Reason: reason}
+		}
+	}
+
+	result := tx.Model(&models.Reservation{}).
+		Where("id = ? AND status = ?", r.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Changed concurrently since r was loaded
+		return &TransitionError{Entity: "reservation", From: from, To: to}
+	}
+	r.Status = to
+
+	if err := RecordReservationEvent(tx, r.ID, TimelineStatusChanged, from, to, nil); err != nil {
+		return err
+	}
+
+	if HoldsSeat(from) && !HoldsSeat(to) {
+		if err := SyncScheduleAvailability(tx, r.ScheduleID); err != nil {
+			return err
+		}
+		if err := releasePrivateSchedule(tx, r.ScheduleID); err != nil {
+			return err
+		}
+	}
+
+	payload := events.NewReservationPayload(r, from)
+	payload.Source = ActorFrom(tx).Source
+
+	switch to {
+	case ReservationPaid:
+		return events.Publish(tx, events.ReservationPaid, r.ID, payload)
+	case ReservationCancelled:
+		return events.Publish(tx, events.ReservationCancelled, r.ID, payload)
+	case ReservationRefunded:
+		return events.Publish(tx, events.ReservationRefunded, r.ID, payload)
+	}
+
+	return nil
+}
+
+// SyncScheduleAvailability locks the schedule and marks it available when it
+// has fewer active reservations than the court capacity and no blackout covers it
+func SyncScheduleAvailability(tx *gorm.DB, scheduleID string) error {
+	var schedule models.Schedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Court").
+		First(&schedule, "id = ?", scheduleID).Error; err != nil {
+		return err
+	}
+
+	bookedSeats, err := BookedSeats(tx, scheduleID)
+	if err != nil {
+		return err
+	}
+
+	blackedOut, err := ScheduleBlackedOut(tx, &schedule)
+	if err != nil {
+		return err
+	}
+
+	// Private sessions are never opened up for public booking, and nothing
+	// reopens during a blackout
+	available := bookedSeats < schedule.SeatCapacity(schedule.Court) && !schedule.IsPrivate && !blackedOut
+	if available == schedule.IsAvailable {
+		return nil
+	}
+	return tx.Model(&schedule).Update("is_available", available).Error
+}
+
+// releasePrivateSchedule frees the court time of a private session once no
+// reservation holds it (expired, cancelled or refunded). The schedule is kept
+// for the reservation's history but no longer blocks other schedules.
+func releasePrivateSchedule(tx *gorm.DB, scheduleID string) error {
+	var schedule models.Schedule
+	if err := tx.First(&schedule, "id = ?", scheduleID).Error; err != nil {
+		return err
+	}
+	if !schedule.IsPrivate || schedule.ReleasedAt != nil {
+		return nil
+	}
+
+	bookedSeats, err := BookedSeats(tx, scheduleID)
+	if err != nil || bookedSeats > 0 {
+		return err
+	}
+
+	if err := tx.Model(&schedule).Update("released_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	return events.Publish(tx, events.ScheduleChanged, schedule.ID, events.ScheduleChangedPayload{
+		Action:      "released",
+		ScheduleIDs: []string{schedule.ID},
+		CourtID:     schedule.CourtID,
+	})
+}
+
+// BookedSeats returns the number of seats held on a schedule by active reservations
+func BookedSeats(tx *gorm.DB, scheduleID string) (int, error) {
+	var seats int64
+	err := tx.Model(&models.Reservation{}).
+		Where("schedule_id = ? AND status IN ?", scheduleID, ActiveReservationStatuses).
+		Select("COALESCE(SUM(seats), 0)").
+		Scan(&seats).Error
+	return int(seats), err
+}
+
+// BookedSeatsBySchedule is BookedSeats for many schedules at once; schedules
+// without active reservations are absent from the map
+func BookedSeatsBySchedule(tx *gorm.DB, scheduleIDs []string) (map[string]int, error) {
+	var rows []struct {
+		ScheduleID string
+		Seats      int
+	}
+	err := tx.Model(&models.Reservation{}).
+		Select("schedule_id, COALESCE(SUM(seats), 0) AS seats").
+		Where("schedule_id IN ? AND status IN ?", scheduleIDs, ActiveReservationStatuses).
+		Group("schedule_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seats := make(map[string]int, len(rows))
+	for _, row := range rows {
+		seats[row.ScheduleID] = row.Seats
+	}
+	return seats, nil
+}
+
+// seatCount treats reservations created before seat counts existed as one seat
+func seatCount(r *models.Reservation) int {
+	if r.Seats < 1 {
+		return 1
+	}
+	return r.Seats
+}
+
+// MoveReservation moves a paid or confirmed reservation to another schedule.
+// The caller must hold row locks on both schedules; to.Court must be loaded.
+func MoveReservation(tx *gorm.DB, r *models.Reservation, to *models.Schedule) error {
+	if r.Status != ReservationPaid && r.Status != ReservationConfirmed {
+		return &GuardError{Entity: "reservation", From: r.Status, To: "rescheduled", Reason: "only paid or confirmed reservations can be moved"}
+	}
+	if r.ScheduleID == to.ID {
+		return &GuardError{Entity: "reservation", From: r.Status, To: "rescheduled", Reason: "already on this schedule"}
+	}
+	if !to.IsAvailable {
+		return &GuardError{Entity: "reservation", From: r.Status, To: "rescheduled", Reason: "target schedule is not available"}
+	}
+
+	bookedSeats, err := BookedSeats(tx, to.ID)
+	if err != nil {
+		return err
+	}
+	if bookedSeats+seatCount(r) > to.SeatCapacity(to.Court) {
+		return &GuardError{Entity: "reservation", From: r.Status, To: "rescheduled", Reason: "target schedule is fully booked"}
+	}
+
+	fromScheduleID := r.ScheduleID
+	totalAmount := to.SlotPrice(to.Court) * float64(seatCount(r))
+	if err := tx.Model(&models.Reservation{}).Where("id = ?", r.ID).Updates(map[string]interface{}{
+		"schedule_id":  to.ID,
+		"court_id":     to.CourtID,
+		"total_amount": totalAmount,
+	}).Error; err != nil {
+		return err
+	}
+	r.ScheduleID = to.ID
+	r.CourtID = to.CourtID
+	r.TotalAmount = totalAmount
+
+	if err := SyncScheduleAvailability(tx, fromScheduleID); err != nil {
+		return err
+	}
+	if err := SyncScheduleAvailability(tx, to.ID); err != nil {
+		return err
+	}
+
+	if err := RecordReservationEvent(tx, r.ID, TimelineRescheduled, r.Status, r.Status, map[string]interface{}{
+		"from_schedule_id": fromScheduleID,
+		"to_schedule_id":   to.ID,
+		"total_amount":     r.TotalAmount,
+	}); err != nil {
+		return err
+	}
+
+	payload := events.NewReservationPayload(r, r.Status)
+	return events.Publish(tx, events.ReservationRescheduled, r.ID, events.RescheduledPayload{
+		ReservationPayload: payload,
+		FromScheduleID:     fromScheduleID,
+	})
+}
+
+// revertReschedule moves a reservation back to the slot it left when the
+// top-up for that move fails or expires. A reservation that has moved on since,
+// or whose old slot can no longer take it, stays put and is flagged for review
+// (see flagRescheduleForReview).
+func revertReschedule(tx *gorm.DB, adjustment *models.Payment) error {
+	var reschedule models.ReservationReschedule
+	err := tx.First(&reschedule, "adjustment_payment_id = ?", adjustment.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if reschedule.RevertedAt != nil {
+		return nil
+	}
+
+	var reservation models.Reservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", reschedule.ReservationID).Error; err != nil {
+		return err
+	}
+	if reservation.ScheduleID != reschedule.ToScheduleID {
+		return flagRescheduleForReview(tx, &reschedule, "top-up failed but the reservation has moved on since")
+	}
+
+	var from models.Schedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Court").First(&from, "id = ?", reschedule.FromScheduleID).Error; err != nil {
+		return err
+	}
+
+	if err := MoveReservation(tx, &reservation, &from); err != nil {
+		var guard *GuardError
+		if errors.As(err, &guard) {
+			return flagRescheduleForReview(tx, &reschedule, "top-up failed but the old schedule cannot take the reservation back: "+guard.Reason)
+		}
+		return err
+	}
+
+	return tx.Model(&reschedule).Update("reverted_at", time.Now()).Error
+}
+
+// flagRescheduleForReview leaves a move whose top-up was never paid in place
+// and lists it for admins, who settle the difference or move the guest by hand
+func flagRescheduleForReview(tx *gorm.DB, reschedule *models.ReservationReschedule, note string) error {
+	log.Printf("Reschedule %s needs review: %s", reschedule.ID, note)
+	return tx.Model(reschedule).Updates(map[string]interface{}{
+		"needs_review_at": time.Now(),
+		"review_note":     note,
+	}).Error
+}
+
+// CheckInReservation marks a paid or confirmed reservation as attended.
+// Checking in twice is rejected so the timeline keeps the first arrival.
+func CheckInReservation(tx *gorm.DB, r *models.Reservation) error {
+	if r.Status != ReservationPaid && r.Status != ReservationConfirmed {
+		return &GuardError{Entity: "reservation", From: r.Status, To: "checked_in", Reason: "only paid or confirmed reservations can be checked in"}
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.Reservation{}).
+		Where("id = ? AND status = ? AND checked_in_at IS NULL", r.ID, r.Status).
+		Update("checked_in_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &GuardError{Entity: "reservation", From: r.Status, To: "checked_in", Reason: "already checked in or changed concurrently"}
+	}
+	r.CheckedInAt = &now
+
+	if err := RecordReservationEvent(tx, r.ID, TimelineCheckedIn, r.Status, r.Status, map[string]interface{}{
+		"checked_in_at": now,
+	}); err != nil {
+		return err
+	}
+
+	payload := events.NewReservationPayload(r, r.Status)
+	payload.Source = ActorFrom(tx).Source
+	return events.Publish(tx, events.ReservationCheckedIn, r.ID, payload)
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// ScheduleOverlapConstraint is the exclusion constraint that keeps schedules
+// on the same court from overlapping
+const ScheduleOverlapConstraint = "schedules_no_overlap"
+
+// ScheduleStart is the absolute start of the schedule in the studio timezone
+func ScheduleStart(s *models.Schedule) time.Time {
+	return s.StartsAt()
+}
+
+// ScheduleEnd is the absolute end of the schedule in the studio timezone
+func ScheduleEnd(s *models.Schedule) time.Time {
+	return s.EndsAt()
+}
+
+// OverlappingSchedules returns the schedules on the court and date whose time
+// range intersects [start, end). excludeID skips the schedule being edited.
+func OverlappingSchedules(tx *gorm.DB, courtID string, date time.Time, start, end models.TimeOfDay, excludeID string) ([]models.Schedule, error) {
+	query := tx.Where("court_id = ? AND date = ? AND start_time < ? AND end_time > ? AND released_at IS NULL",
+		courtID, date.Format("2006-01-02"), end, start)
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+
+	var schedules []models.Schedule
+	err := query.Order("start_time ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// EnsureScheduleOverlapConstraint adds ScheduleOverlapConstraint, the database
+// side of OverlappingSchedules. It is deferrable so that statements moving
+// adjacent slots together (bulk shifts) are checked once they complete, and
+// skips released private sessions. An older version of the constraint without
+// that predicate is replaced. Adding it fails while overlapping schedules exist.
+func EnsureScheduleOverlapConstraint(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist").Error; err != nil {
+		return err
+	}
+
+	var definition string
+	if err := db.Raw("SELECT COALESCE((SELECT pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = ?), '')", ScheduleOverlapConstraint).
+		Scan(&definition).Error; err != nil {
+		return err
+	}
+	if strings.Contains(definition, "released_at") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if definition != "" {
+			if err := tx.Exec("ALTER TABLE schedules DROP CONSTRAINT " + ScheduleOverlapConstraint).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec(`ALTER TABLE schedules ADD CONSTRAINT ` + ScheduleOverlapConstraint + `
+			EXCLUDE USING gist (court_id WITH =, tsrange(date + start_time, date + end_time) WITH &&)
+			WHERE (released_at IS NULL)
+			DEFERRABLE INITIALLY IMMEDIATE`).Error
+	})
+}
+
+// IsScheduleOverlap reports whether err is a violation of
+// ScheduleOverlapConstraint, i.e. a concurrent write won the slot
+func IsScheduleOverlap(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == ScheduleOverlapConstraint
+}
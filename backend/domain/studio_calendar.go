@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// StudioCalendar holds the operating hours, holidays and blackouts relevant
+// to scheduling one court over a date range, so slots can be checked without
+// a query each
+type StudioCalendar struct {
+	courtID   string
+	hours     map[int]models.OperatingHours // weekday -> effective hours
+	holidays  map[string]string             // YYYY-MM-DD -> name
+	blackouts []models.Blackout
+}
+
+// LoadStudioCalendar loads the calendar of courtID for the dates from..to inclusive
+func LoadStudioCalendar(tx *gorm.DB, courtID string, from, to time.Time) (*StudioCalendar, error) {
+	cal := &StudioCalendar{
+		courtID:  courtID,
+		hours:    map[int]models.OperatingHours{},
+		holidays: map[string]string{},
+	}
+
+	var hours []models.OperatingHours
+	if err := tx.Where("court_id IS NULL OR court_id = ?", courtID).Find(&hours).Error; err != nil {
+		return nil, err
+	}
+	// Studio-wide rows first so the court's own rows override them
+	for _, h := range hours {
+		if h.CourtID == nil {
+			cal.hours[h.Weekday] = h
+		}
+	}
+	for _, h := range hours {
+		if h.CourtID != nil {
+			cal.hours[h.Weekday] = h
+		}
+	}
+
+	var holidays []models.Holiday
+	if err := tx.Where("date BETWEEN ? AND ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Find(&holidays).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range holidays {
+		cal.holidays[h.Date.Format("2006-01-02")] = h.Name
+	}
+
+	// Widen by a day on each side: the range is in studio dates, blackouts are absolute
+	rangeStart := models.TimeOfDay(0).On(from).AddDate(0, 0, -1)
+	rangeEnd := models.TimeOfDay(0).On(to).AddDate(0, 0, 2)
+	if err := tx.Where("(court_id IS NULL OR court_id = ?) AND starts_at < ? AND ends_at > ?", courtID, rangeEnd, rangeStart).
+		Find(&cal.blackouts).Error; err != nil {
+		return nil, err
+	}
+
+	return cal, nil
+}
+
+// Closure returns why a slot cannot be scheduled, or "" when it can
+func (cal *StudioCalendar) Closure(date time.Time, start, end models.TimeOfDay) string {
+	day := date.Format("2006-01-02")
+	if name, ok := cal.holidays[day]; ok {
+		return "holiday: " + name
+	}
+
+	if h, ok := cal.hours[int(date.Weekday())]; ok {
+		if h.Closed {
+			return "closed on " + date.Weekday().String()
+		}
+		if start < h.OpenTime || end > h.CloseTime {
+			return fmt.Sprintf("outside operating hours %s - %s", h.OpenTime, h.CloseTime)
+		}
+	}
+
+	startsAt, endsAt := start.On(date), end.On(date)
+	for _, b := range cal.blackouts {
+		if b.StartsAt.Before(endsAt) && b.EndsAt.After(startsAt) {
+			if b.Reason != "" {
+				return "blackout: " + b.Reason
+			}
+			return "blackout"
+		}
+	}
+
+	return ""
+}
+
+// ScheduleBlackedOut reports whether a blackout covers any part of s
+func ScheduleBlackedOut(tx *gorm.DB, s *models.Schedule) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Blackout{}).
+		Where("(court_id IS NULL OR court_id = ?) AND starts_at < ? AND ends_at > ?", s.CourtID, s.EndsAt(), s.StartsAt()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SchedulesInBlackout returns the schedules that overlap b
+func SchedulesInBlackout(tx *gorm.DB, b *models.Blackout) ([]models.Schedule, error) {
+	query := tx.Where("date BETWEEN ? AND ?",
+		b.StartsAt.Add(-24*time.Hour).Format("2006-01-02"),
+		b.EndsAt.Add(24*time.Hour).Format("2006-01-02"))
+	if b.CourtID != nil {
+		query = query.Where("court_id = ?", *b.CourtID)
+	}
+
+	var candidates []models.Schedule
+	if err := query.Order("date ASC, start_time ASC").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	schedules := []models.Schedule{}
+	for _, s := range candidates {
+		if s.StartsAt().Before(b.EndsAt) && s.EndsAt().After(b.StartsAt) {
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
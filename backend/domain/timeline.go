@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// Timeline event types
+const (
+	TimelineCreated              = "created"
+	TimelineStatusChanged        = "status_changed"
+	TimelinePaymentStatusChanged = "payment_status_changed"
+	TimelinePartialRefund        = "payment_partially_refunded"
+	TimelineRescheduled          = "rescheduled"
+	TimelineCheckedIn            = "checked_in"
+)
+
+// Sources of a change, i.e. the path through which it reached the domain
+const (
+	SourceUser            = "user"
+	SourceAdmin           = "admin"
+	SourceWebhook         = "webhook"
+	SourceWebhookReplay   = "webhook_replay"
+	SourceMarkPaid        = "mark_paid"
+	SourceProactiveCheck  = "proactive_check"
+	SourceManualBooking   = "manual_booking"
+	SourceAccountDeletion = "account_deletion"
+	SourceSystem          = "system"
+)
+
+// EnsureTimelineAppendOnly installs a trigger that makes reservation_events
+// reject UPDATE and DELETE with an error, so history cannot be rewritten
+// silently. Changes made by foreign key actions (a reservation or user being
+// deleted) run nested in another trigger and are let through, so cascades are
+// not blocked. Rules from older schema.sql versions, which swallowed writes
+// instead of failing them, are dropped.
+func EnsureTimelineAppendOnly(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"DROP RULE IF EXISTS reservation_events_no_update ON reservation_events",
+			"DROP RULE IF EXISTS reservation_events_no_delete ON reservation_events",
+			`CREATE OR REPLACE FUNCTION reservation_events_append_only() RETURNS trigger AS $$
+			BEGIN
+				IF pg_trigger_depth() > 1 THEN
+					IF TG_OP = 'DELETE' THEN
+						RETURN OLD;
+					END IF;
+					RETURN NEW;
+				END IF;
+				RAISE EXCEPTION 'reservation_events is append-only (% rejected)', TG_OP
+					USING ERRCODE = 'restrict_violation';
+			END;
+			$$ LANGUAGE plpgsql`,
+			"DROP TRIGGER IF EXISTS reservation_events_append_only ON reservation_events",
+			`CREATE TRIGGER reservation_events_append_only
+			BEFORE UPDATE OR DELETE ON reservation_events
+			FOR EACH ROW EXECUTE FUNCTION reservation_events_append_only()`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Actor is who caused a change and through which path. UserID is empty for
+// changes made by the payment gateway or background jobs.
+type Actor struct {
+	UserID         string
+	ImpersonatorID string
+	Source         string
+}
+
+type actorKey struct{}
+
+// WithActor returns tx carrying actor, so the transitions made through it are
+// attributed in the reservation timeline
+func WithActor(tx *gorm.DB, actor Actor) *gorm.DB {
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tx.WithContext(context.WithValue(ctx, actorKey{}, actor))
+}
+
+// ActorFrom returns the actor attached to tx, defaulting to the system
+func ActorFrom(tx *gorm.DB) Actor {
+	if tx.Statement.Context != nil {
+		if actor, ok := tx.Statement.Context.Value(actorKey{}).(Actor); ok {
+			return actor
+		}
+	}
+	return Actor{Source: SourceSystem}
+}
+
+// RecordReservationEvent appends an entry to the reservation timeline,
+// attributed to the actor carried by tx
+func RecordReservationEvent(tx *gorm.DB, reservationID, eventType, from, to string, payload interface{}) error {
+	actor := ActorFrom(tx)
+
+	event := models.ReservationEvent{
+		ReservationID: reservationID,
+		Type:          eventType,
+		FromStatus:    from,
+		ToStatus:      to,
+		Source:        actor.Source,
+	}
+	if actor.UserID != "" {
+		event.ActorID = &actor.UserID
+	}
+	if actor.ImpersonatorID != "" {
+		event.ImpersonatorID = &actor.ImpersonatorID
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		event.Payload = data
+	}
+
+	return tx.Create(&event).Error
+}
@@ -0,0 +1,223 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultBatchSize    = 50
+	maxAttempts         = 10
+	maxBackoff          = time.Hour
+	// claimLease keeps a claimed event from other dispatchers while its
+	// handlers run; an instance that dies mid-delivery is retried after it
+	claimLease = 5 * time.Minute
+)
+
+// Handler processes one event. Delivery is at least once, so handlers must
+// tolerate seeing the same event ID more than once.
+type Handler func(ctx context.Context, e Event) error
+
+type subscription struct {
+	name    string
+	handler Handler
+}
+
+// Dispatcher polls the outbox and delivers events to subscribers. Each
+// subscriber's success is recorded, so when some fail only those are retried,
+// with exponential backoff.
+//
+// Every event is handled by exactly one instance. That suits handlers with
+// side effects in the database or elsewhere; handlers that only update
+// in-process state (e.g. live streams) see just the events their own instance
+// dispatched.
+type Dispatcher struct {
+	DB           *gorm.DB
+	PollInterval time.Duration
+
+	mu          sync.RWMutex
+	subscribers map[string][]subscription
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:           db,
+		PollInterval: defaultPollInterval,
+		subscribers:  map[string][]subscription{},
+	}
+}
+
+// Subscribe registers handler for eventType under a name used in logs
+func (d *Dispatcher) Subscribe(eventType string, name string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscribers[eventType] = append(d.subscribers[eventType], subscription{name: name, handler: handler})
+}
+
+// Start runs the dispatch loop until ctx is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			for d.dispatchBatch(ctx) == defaultBatchSize {
+				// Keep draining while batches are full
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// dispatchBatch delivers due events and returns how many were claimed
+func (d *Dispatcher) dispatchBatch(ctx context.Context) int {
+	claimed, err := d.claim()
+	if err != nil {
+		log.Printf("Outbox dispatch failed: %v", err)
+		return 0
+	}
+
+	for i := range claimed {
+		d.deliver(ctx, &claimed[i])
+	}
+	return len(claimed)
+}
+
+// claim picks due events and leases them for claimLease in a short
+// transaction, so handlers (SMTP, HTTP) run without holding a row lock or a
+// database connection
+func (d *Dispatcher) claim() ([]models.OutboxEvent, error) {
+	var claimed []models.OutboxEvent
+
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED lets several instances share the outbox safely
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("created_at ASC").
+			Limit(defaultBatchSize).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(claimed))
+		for _, row := range claimed {
+			ids = append(ids, row.ID)
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(claimLease)).Error
+	})
+
+	return claimed, err
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, row *models.OutboxEvent) {
+	event := Event{
+		ID:          row.ID,
+		Type:        row.Type,
+		AggregateID: row.AggregateID,
+		Payload:     row.Payload,
+		CreatedAt:   row.CreatedAt,
+	}
+
+	d.mu.RLock()
+	subs := d.subscribers[row.Type]
+	d.mu.RUnlock()
+
+	var delivered []string
+	deliveryErr := d.DB.Model(&models.OutboxDelivery{}).
+		Where("event_id = ?", row.ID).
+		Pluck("subscriber", &delivered).Error
+
+	if deliveryErr == nil {
+		done := make(map[string]bool, len(delivered))
+		for _, name := range delivered {
+			done[name] = true
+		}
+
+		var failures []string
+		for _, sub := range subs {
+			if done[sub.name] {
+				continue
+			}
+			if err := safeHandle(ctx, sub.handler, event); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", sub.name, err))
+				continue
+			}
+			if err := d.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.OutboxDelivery{
+				EventID:     row.ID,
+				Subscriber:  sub.name,
+				DeliveredAt: time.Now(),
+			}).Error; err != nil {
+				// The handler ran; it will see the event again on retry
+				failures = append(failures, fmt.Sprintf("%s: recording delivery: %v", sub.name, err))
+			}
+		}
+		if len(failures) > 0 {
+			deliveryErr = errors.New(strings.Join(failures, "; "))
+		}
+	}
+
+	now := time.Now()
+	row.Attempts++
+
+	if deliveryErr == nil {
+		row.DispatchedAt = &now
+		row.LastError = ""
+	} else {
+		row.LastError = deliveryErr.Error()
+		if row.Attempts >= maxAttempts {
+			row.FailedAt = &now
+			log.Printf("Outbox event %s (%s) gave up after %d attempts: %v", row.ID, row.Type, row.Attempts, deliveryErr)
+		} else {
+			row.NextAttemptAt = now.Add(backoff(row.Attempts))
+		}
+	}
+
+	if err := d.DB.Model(row).Updates(map[string]interface{}{
+		"attempts":        row.Attempts,
+		"last_error":      row.LastError,
+		"dispatched_at":   row.DispatchedAt,
+		"failed_at":       row.FailedAt,
+		"next_attempt_at": row.NextAttemptAt,
+	}).Error; err != nil {
+		log.Printf("Failed to update outbox event %s: %v", row.ID, err)
+	}
+}
+
+// backoff doubles from 2s per attempt, capped at maxBackoff
+func backoff(attempt int) time.Duration {
+	d := time.Duration(1<<uint(attempt)) * time.Second
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// safeHandle turns a panicking subscriber into a delivery error
+func safeHandle(ctx context.Context, h Handler, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, e)
+}
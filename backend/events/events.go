@@ -0,0 +1,107 @@
+// Package events implements the transactional outbox and the in-process
+// event bus. Publish writes an event inside the caller's transaction; the
+// Dispatcher delivers committed events to subscribers at least once.
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// Event types
+const (
+	ReservationCreated     = "ReservationCreated"
+	ReservationPaid        = "ReservationPaid"
+	ReservationCancelled   = "ReservationCancelled"
+	ReservationRefunded    = "ReservationRefunded"
+	ScheduleChanged        = "ScheduleChanged"
+	PaymentRefunded        = "PaymentRefunded"
+	ReservationRescheduled = "ReservationRescheduled"
+	ReservationCheckedIn   = "ReservationCheckedIn"
+)
+
+// Event is what subscribers receive
+type Event struct {
+	ID          string
+	Type        string
+	AggregateID string
+	Payload     json.RawMessage
+	CreatedAt   time.Time
+}
+
+// Decode unmarshals the payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// ReservationPayload is sent with ReservationCreated, ReservationPaid,
+// ReservationCancelled, ReservationRefunded and ReservationCheckedIn
+type ReservationPayload struct {
+	ReservationID string  `json:"reservation_id"`
+	UserID        string  `json:"user_id"`
+	ScheduleID    string  `json:"schedule_id"`
+	CourtID       string  `json:"court_id"`
+	FromStatus    string  `json:"from_status,omitempty"`
+	Status        string  `json:"status"`
+	TotalAmount   float64 `json:"total_amount"`
+	// Source is the path that caused the change (e.g. user, admin, webhook)
+	Source string `json:"source,omitempty"`
+}
+
+// RescheduledPayload is sent with ReservationRescheduled; ScheduleID is the new schedule
+type RescheduledPayload struct {
+	ReservationPayload
+	FromScheduleID string `json:"from_schedule_id"`
+}
+
+// ScheduleChangedPayload is sent when admins create or edit schedules
+type ScheduleChangedPayload struct {
+	Action      string   `json:"action"` // created, updated, closed, reopened, shifted, deleted, released
+	ScheduleIDs []string `json:"schedule_ids"`
+	CourtID     string   `json:"court_id,omitempty"`
+	// Dates maps deleted schedule IDs to their date (YYYY-MM-DD), since
+	// subscribers can no longer load them
+	Dates map[string]string `json:"dates,omitempty"`
+}
+
+// PaymentPayload is sent with PaymentRefunded
+type PaymentPayload struct {
+	PaymentID     string  `json:"payment_id"`
+	ReservationID string  `json:"reservation_id"`
+	OrderID       string  `json:"order_id"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"`
+}
+
+// Publish writes the event to the outbox using tx, so it is only delivered
+// if the surrounding transaction commits
+func Publish(tx *gorm.DB, eventType string, aggregateID string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxEvent{
+		Type:          eventType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// NewReservationPayload builds the payload for a reservation event
+func NewReservationPayload(r *models.Reservation, fromStatus string) ReservationPayload {
+	return ReservationPayload{
+		ReservationID: r.ID,
+		UserID:        r.UserID,
+		ScheduleID:    r.ScheduleID,
+		CourtID:       r.CourtID,
+		FromStatus:    fromStatus,
+		Status:        r.Status,
+		TotalAmount:   r.TotalAmount,
+	}
+}
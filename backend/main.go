@@ -1,23 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	_ "time/tzdata" // studio timezone must resolve even on hosts without a tz database
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"github.com/Giriathallah/diro-pilates-backend/controllers"
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
 	"github.com/Giriathallah/diro-pilates-backend/middleware"
 	"github.com/Giriathallah/diro-pilates-backend/models"
 	"github.com/Giriathallah/diro-pilates-backend/routes"
 	"github.com/Giriathallah/diro-pilates-backend/seed"
 	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
 )
 
 var DB *gorm.DB
@@ -27,12 +33,14 @@ func main() {
 		log.Println("No .env file found")
 	}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Singapore",
+	// The session timezone is the studio's, so CURRENT_DATE and date casts agree with it
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=%s",
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
 		os.Getenv("DB_NAME"),
 		os.Getenv("DB_PORT"),
+		utils.StudioTimezone(),
 	)
 
 	var err error
@@ -49,13 +57,49 @@ func main() {
 		&models.User{},
 		&models.Court{},
 		&models.Schedule{},
+		&models.Booking{},
 		&models.Reservation{},
+		&models.ReservationAttendee{},
 		&models.Payment{},
+		&models.UserPermission{},
+		&models.AuditLog{},
+		&models.APIKey{},
+		&models.UserIdentity{},
+		&models.IdempotencyKey{},
+		&models.WebhookEvent{},
+		&models.LedgerEntry{},
+		&models.OutboxEvent{},
+		&models.OutboxDelivery{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeliveryAttempt{},
+		&models.ReservationReschedule{},
+		&models.ReservationEvent{},
+		&models.SessionRequest{},
+		&models.NotificationPreference{},
+		&models.NotificationDelivery{},
+		&models.ClassReminder{},
+		&models.PhoneVerification{},
+		&models.Notification{},
+		&models.OperatingHours{},
+		&models.Holiday{},
+		&models.Blackout{},
 	)
 	if err != nil {
 		log.Fatal("AutoMigrate failed:", err)
 	}
 
+	// The reservation timeline is append-only; AutoMigrate cannot install the trigger
+	if err := domain.EnsureTimelineAppendOnly(DB); err != nil {
+		log.Fatal("Reservation timeline trigger could not be installed: ", err)
+	}
+
+	// Schedules on the same court must not overlap; AutoMigrate cannot express this.
+	// Booking code relies on it, so the server does not start without it.
+	if err := domain.EnsureScheduleOverlapConstraint(DB); err != nil {
+		log.Fatal("Schedule overlap constraint could not be installed (remove overlapping schedules and restart): ", err)
+	}
+
 	// if err := godotenv.Load(); err != nil {
 	// 	log.Println("Warning: .env file not found")
 	// }
@@ -69,21 +113,87 @@ func main() {
 
 	// Services
 	midtransService := services.NewMidtransService()
+	oidcService := services.NewOIDCService()
+	mailer := services.NewMailerFromEnv()
+	messaging := services.NewMessagingProviderFromEnv()
+
+	// Domain events: delivered from the outbox to in-process subscribers
+	dispatcher := events.NewDispatcher(DB)
+	if os.Getenv("APP_ENV") == "development" {
+		for _, eventType := range []string{events.ReservationCreated, events.ReservationPaid, events.ReservationCancelled, events.ReservationRefunded, events.ReservationRescheduled, events.ScheduleChanged, events.PaymentRefunded} {
+			dispatcher.Subscribe(eventType, "dev-logger", func(ctx context.Context, e events.Event) error {
+				log.Printf("Event %s %s: %s", e.Type, e.AggregateID, string(e.Payload))
+				return nil
+			})
+		}
+	}
+
+	// Outbound webhooks to third-party integrations
+	webhookSender := services.NewWebhookSender(DB)
+	for _, eventType := range services.OutboundWebhookEvents {
+		dispatcher.Subscribe(eventType, "outbound-webhooks", webhookSender.Enqueue)
+	}
+	webhookSender.Start(context.Background())
+
+	// Customer notifications: email (written to MAIL_SINK_FILE or the log in
+	// development) and WhatsApp for users who verified their phone and opted in
+	notifier := services.NewNotifier(DB,
+		services.EmailChannel{Mailer: mailer},
+		services.WhatsAppChannel{Provider: messaging},
+	)
+	for _, eventType := range services.NotificationEvents {
+		dispatcher.Subscribe(eventType, "notifications", notifier.HandleEvent)
+	}
+
+	inbox := services.NewInbox(DB, notifier)
+	for _, eventType := range services.InboxEvents {
+		dispatcher.Subscribe(eventType, "in-app-inbox", inbox.HandleEvent)
+	}
+
+	reminders := services.NewReminderScheduler(DB, notifier)
+	for _, eventType := range services.ReminderEvents {
+		dispatcher.Subscribe(eventType, "class-reminders", reminders.HandleEvent)
+	}
+	reminders.Start(context.Background())
+
+	// Live seat counts for /api/schedules/stream
+	seatStream := services.NewSeatStream(DB)
+	for _, eventType := range services.SeatStreamEvents {
+		dispatcher.Subscribe(eventType, "seat-stream", seatStream.HandleEvent)
+	}
+
+	// Live admin agenda for /api/admin/agenda/stream
+	agendaStream := services.NewAgendaStream(DB)
+	for _, eventType := range services.AgendaEvents {
+		dispatcher.Subscribe(eventType, "admin-agenda", agendaStream.HandleEvent)
+	}
+
+	dispatcher.Start(context.Background())
+
+	services.StartSessionRequestExpiry(context.Background(), DB, midtransService)
+	services.StartAdjustmentPaymentExpiry(context.Background(), DB, midtransService)
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: middleware.ErrorHandler,
 	})
 
-	app.Use(logger.New()) // Logging middleware
+	app.Use(logger.New())  // Logging middleware
+	app.Use(recover.New()) // A panicking handler fails its request, not the process
+
+	// Audit trail for requests made while an admin impersonates a user
+	app.Use(middleware.AuditImpersonation(DB))
+
+	// API key authentication for kiosk/partner integrations (accepted alongside JWT)
+	app.Use(middleware.APIKeyAuth(DB))
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-API-Key, Idempotency-Key",
 		AllowCredentials: true,
 	}))
 
 	// Setup routes
-	setupRoutes(app, midtransService)
+	setupRoutes(app, midtransService, oidcService, webhookSender, mailer, messaging, notifier, seatStream, agendaStream)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -92,17 +202,35 @@ func main() {
 	log.Fatal(app.Listen(":" + port))
 }
 
-func setupRoutes(app *fiber.App, mt *services.MidtransService) {
-	routes.SetupAuthRoutes(app, DB)
+func setupRoutes(app *fiber.App, mt *services.MidtransService, oidc *services.OIDCService, webhookSender *services.WebhookSender, mailer services.Mailer, messaging services.MessagingProvider, notifier *services.Notifier, seatStream *services.SeatStream, agendaStream *services.AgendaStream) {
+	routes.SetupAuthRoutes(app, DB, oidc)
+	routes.SetupProfileRoutes(app, DB, messaging)
 	routes.SetupReservationRoutes(app, DB, mt)
+	routes.SetupSeatStreamRoutes(app, controllers.NewSeatStreamController(seatStream))
+
+	attendeeCtrl := controllers.NewAttendeeController(DB, mailer)
+	routes.SetupAttendeeRoutes(app, DB, attendeeCtrl)
+
+	sessionRequestCtrl := controllers.NewSessionRequestController(DB, mt)
+	routes.SetupSessionRequestRoutes(app, DB, sessionRequestCtrl)
+
+	notificationCtrl := controllers.NewNotificationController(DB, notifier)
+	routes.SetupNotificationRoutes(app, DB, notificationCtrl)
+
+	routes.SetupCalendarRoutes(app, DB, controllers.NewCalendarController(DB))
 
 	// Admin Routes (Initialize controllers needed)
-	adminCtrl := controllers.NewAdminController(DB)
+	adminCtrl := controllers.NewAdminController(DB, agendaStream)
 	courtCtrl := controllers.NewCourtController(DB)
 	scheduleCtrl := controllers.NewScheduleController(DB)
 	resCtrl := controllers.NewReservationController(DB, mt)
+	impersonationCtrl := controllers.NewImpersonationController(DB)
+	apiKeyCtrl := controllers.NewAPIKeyController(DB)
+	webhookSubCtrl := controllers.NewWebhookSubscriptionController(DB, webhookSender)
+	studioCalendarCtrl := controllers.NewStudioCalendarController(DB)
+	blackoutCtrl := controllers.NewBlackoutController(DB, mt)
 
-	routes.SetupAdminRoutes(app, DB, adminCtrl, courtCtrl, scheduleCtrl, resCtrl)
+	routes.SetupAdminRoutes(app, DB, adminCtrl, courtCtrl, scheduleCtrl, resCtrl, impersonationCtrl, apiKeyCtrl, webhookSubCtrl, attendeeCtrl, sessionRequestCtrl, notificationCtrl, studioCalendarCtrl, blackoutCtrl)
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Pilates API Running")
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/Giriathallah/diro-pilates-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// AuditImpersonation writes every state-changing request made with an
+// impersonation token to the audit trail, recording both the admin and the
+// impersonated user. It must be registered before the route groups so it
+// can read the locals set by Protected once the handler has run.
+func AuditImpersonation(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		impersonatorID, ok := c.Locals("impersonator_id").(string)
+		if !ok || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead || c.Method() == fiber.MethodOptions {
+			return err
+		}
+
+		userID, _ := c.Locals("user_id").(string)
+		services.RecordAudit(db, services.AuditEntry{
+			UserID:         userID,
+			ImpersonatorID: impersonatorID,
+			Action:         "impersonated_request",
+			NewData: fiber.Map{
+				"method": c.Method(),
+				"path":   c.Path(),
+				"status": c.Response().StatusCode(),
+			},
+			IPAddress: c.IP(),
+		})
+
+		return err
+	}
+}
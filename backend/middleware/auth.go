@@ -3,13 +3,23 @@ package middleware
 import (
 	"strings"
 
+	"github.com/Giriathallah/diro-pilates-backend/models"
 	"github.com/Giriathallah/diro-pilates-backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
-func Protected() fiber.Handler {
+// Protected authenticates the request by JWT (cookie or bearer) unless an API
+// key already did. Tokens of deleted (anonymized) accounts are rejected even
+// before they expire.
+func Protected(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Already authenticated by APIKeyAuth
+		if c.Locals("api_key_id") != nil {
+			return c.Next()
+		}
+
 		var tokenString string
 
 		// 1. Check Cookie first
@@ -35,16 +45,32 @@ func Protected() fiber.Handler {
 			})
 		}
 
+		// Only session tokens carry a string user_id and no typ; other signed
+		// claims (e.g. OIDC state) must not authenticate
 		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		userID, hasUser := claims["user_id"].(string)
+		if _, typed := claims["typ"]; !ok || !hasUser || userID == "" || typed {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid token claims",
 			})
 		}
 
+		var user models.User
+		if err := db.Select("id", "anonymized_at").First(&user, "id = ?", userID).Error; err != nil || user.AnonymizedAt != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Account no longer exists",
+			})
+		}
+
 		// Simpan data user ke locals context untuk dipakai di controller
-		c.Locals("user_id", claims["user_id"])
+		c.Locals("user_id", userID)
 		c.Locals("role", claims["role"])
+		if impersonatorID, ok := claims["impersonator_id"].(string); ok && impersonatorID != "" {
+			c.Locals("impersonator_id", impersonatorID)
+		}
+		if issuedAt, ok := claims["iat"].(float64); ok {
+			c.Locals("issued_at", int64(issuedAt))
+		}
 
 		return c.Next()
 	}
@@ -62,3 +88,16 @@ func AdminOnly() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// NoImpersonation blocks sensitive operations (password change, account
+// deletion, starting another impersonation) during an impersonation session
+func NoImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Locals("impersonator_id") != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden: Not allowed while impersonating",
+			})
+		}
+		return c.Next()
+	}
+}
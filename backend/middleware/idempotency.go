@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+)
+
+// Idempotency replays the stored response when a request is retried with
+// the same Idempotency-Key and payload, and rejects a reused key whose
+// payload differs. Keys are scoped per user, so it must run after Protected.
+// Requests without the header are processed normally.
+func Idempotency(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Idempotency-Key too long"})
+		}
+
+		userID, _ := c.Locals("user_id").(string)
+		if userID == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+
+		fingerprint := sha256.Sum256(append([]byte(c.Method()+" "+c.Path()+"\n"), c.Body()...))
+		requestHash := hex.EncodeToString(fingerprint[:])
+		now := time.Now()
+
+		// Expired keys may be reused
+		db.Where("user_id = ? AND key = ? AND expires_at < ?", userID, key, now).Delete(&models.IdempotencyKey{})
+
+		record := models.IdempotencyKey{
+			Key:         key,
+			UserID:      userID,
+			Method:      c.Method(),
+			Path:        c.Path(),
+			RequestHash: requestHash,
+			ExpiresAt:   now.Add(idempotencyTTL),
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not store idempotency key"})
+		}
+
+		// Key already used: replay or reject
+		if result.RowsAffected == 0 {
+			var existing models.IdempotencyKey
+			if err := db.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Request with this Idempotency-Key is in progress"})
+			}
+
+			if existing.RequestHash != requestHash {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"error": "Idempotency-Key was already used with a different request",
+				})
+			}
+
+			if existing.CompletedAt == nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Request with this Idempotency-Key is in progress"})
+			}
+
+			c.Set("Idempotent-Replayed", "true")
+			if existing.ContentType != "" {
+				c.Set(fiber.HeaderContentType, existing.ContentType)
+			}
+			return c.Status(existing.StatusCode).Send(existing.ResponseBody)
+		}
+
+		if err := c.Next(); err != nil {
+			db.Delete(&record)
+			return err
+		}
+
+		// Server errors are not stored so the client can safely retry
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			db.Delete(&record)
+			return nil
+		}
+
+		completedAt := time.Now()
+		db.Model(&record).Updates(map[string]interface{}{
+			"status_code":   status,
+			"content_type":  string(c.Response().Header.ContentType()),
+			"response_body": c.Response().Body(),
+			"completed_at":  completedAt,
+		})
+
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// HasPermission reports whether the user has been granted perm
+func HasPermission(db *gorm.DB, userID string, perm string) bool {
+	var count int64
+	db.Model(&models.UserPermission{}).
+		Where("user_id = ? AND permission = ?", userID, perm).
+		Count(&count)
+	return count > 0
+}
+
+// RequirePermission must run after Protected
+func RequirePermission(db *gorm.DB, perm string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(string)
+		if userID == "" || !HasPermission(db, userID, perm) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden: Missing permission " + perm,
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyScopes lists the permissions an API key can be granted. A scope is
+// "<resource>:<read|write>", with admin resources prefixed by "admin:".
+var APIKeyScopes = []string{
+	"schedules:read",
+	"dates:read",
+	"timeslots:read",
+	"courts:read",
+	"reservations:read",
+	"reservations:write",
+	"admin:stats:read",
+	"admin:schedules:read",
+	"admin:reservations:read",
+	"admin:reservations:write",
+	"admin:manual-booking:write",
+}
+
+// APIKey authenticates partner and kiosk integrations. Only the SHA-256
+// hash of the key is stored; the plaintext is shown once at creation.
+type APIKey struct {
+	ID                 string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Name               string     `gorm:"not null" json:"name"`
+	Prefix             string     `gorm:"not null" json:"prefix"`
+	KeyHash            string     `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes             string     `gorm:"not null" json:"-"` // comma separated
+	RateLimitPerMinute int        `gorm:"default:60" json:"rate_limit_per_minute"`
+	UserID             string     `gorm:"type:uuid;not null" json:"user_id"` // the account the key acts as
+	User               User       `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	CreatedByID        string     `gorm:"type:uuid;not null" json:"created_by_id"`
+	LastUsedAt         *time.Time `json:"last_used_at"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	RevokedAt          *time.Time `json:"revoked_at"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the key can still authenticate
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
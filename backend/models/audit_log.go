@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog records sensitive actions. When an admin acts on behalf of a
+// customer, UserID is the impersonated user and ImpersonatorID the admin.
+type AuditLog struct {
+	ID             string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID         *string   `gorm:"type:uuid;index" json:"user_id"`
+	ImpersonatorID *string   `gorm:"type:uuid;index" json:"impersonator_id"`
+	Action         string    `gorm:"not null" json:"action"`
+	TableName      string    `gorm:"column:table_name" json:"table_name"`
+	RecordID       *string   `json:"record_id"`
+	OldData        []byte    `gorm:"type:jsonb" json:"old_data"`
+	NewData        []byte    `gorm:"type:jsonb" json:"new_data"`
+	IPAddress      string    `json:"ip_address"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
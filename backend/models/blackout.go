@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// Blackout closes a court (or the whole studio when CourtID is nil) for a
+// period, e.g. maintenance. Schedules inside it stay unavailable until it is
+// removed.
+type Blackout struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	CourtID   *string   `gorm:"type:uuid;index" json:"court_id"`
+	Court     *Court    `json:"court,omitempty"`
+	StartsAt  time.Time `gorm:"not null;index" json:"starts_at"`
+	EndsAt    time.Time `gorm:"not null;index;check:ends_at > starts_at" json:"ends_at"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `gorm:"type:uuid" json:"created_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Booking groups the reservations of one cart checkout. They are paid with a
+// single Snap transaction whose order ID is the booking ID.
+type Booking struct {
+	ID           string        `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID       string        `gorm:"type:uuid;not null;index" json:"user_id"`
+	TotalAmount  float64       `gorm:"type:decimal(10,2);not null" json:"total_amount"`
+	Notes        string        `json:"notes"`
+	Reservations []Reservation `gorm:"foreignKey:BookingID" json:"reservations,omitempty"`
+	CreatedAt    time.Time     `gorm:"autoCreateTime" json:"created_at"`
+}
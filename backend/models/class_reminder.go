@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Class reminder statuses
+const (
+	ReminderQueued    = "queued"
+	ReminderSent      = "sent"
+	ReminderCancelled = "cancelled" // reservation cancelled or moved to another schedule
+	ReminderFailed    = "failed"
+)
+
+// ClassReminder is a reminder queued for one reservation, schedule and offset
+// before the session. The unique key keeps restarts from queueing it twice.
+type ClassReminder struct {
+	ID            string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ReservationID string     `gorm:"type:uuid;not null;uniqueIndex:idx_class_reminders_unique" json:"reservation_id"`
+	ScheduleID    string     `gorm:"type:uuid;not null;uniqueIndex:idx_class_reminders_unique" json:"schedule_id"`
+	OffsetMinutes int        `gorm:"not null;uniqueIndex:idx_class_reminders_unique" json:"offset_minutes"`
+	UserID        string     `gorm:"type:uuid;not null" json:"user_id"`
+	SendAt        time.Time  `gorm:"not null;index" json:"send_at"`
+	Status        string     `gorm:"default:'queued';not null;index" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	LastError     string     `json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
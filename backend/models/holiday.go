@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Holiday sources
+const (
+	HolidaySourceManual = "manual"
+	HolidaySourceICS    = "ics"
+)
+
+// Holiday is a day the whole studio is closed. No schedules are generated on it.
+type Holiday struct {
+	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Date        time.Time `gorm:"type:date;not null;uniqueIndex" json:"date"`
+	Name        string    `gorm:"not null" json:"name"`
+	Source      string    `gorm:"default:'manual';not null" json:"source"`
+	ExternalUID string    `json:"external_uid,omitempty"` // UID of the imported ICS event
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
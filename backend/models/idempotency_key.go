@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey stores the fingerprint and response of a request sent with
+// an Idempotency-Key header so retries replay the original response.
+type IdempotencyKey struct {
+	ID           string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Key          string `gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	UserID       string `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_user_key"`
+	Method       string `gorm:"not null"`
+	Path         string `gorm:"not null"`
+	RequestHash  string `gorm:"not null"`
+	StatusCode   int    // 0 while the original request is still in flight
+	ContentType  string
+	ResponseBody []byte
+	CompletedAt  *time.Time
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
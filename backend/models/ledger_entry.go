@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// LedgerEntry is an append-only accounting record posted when money moves:
+// a positive amount for a settled payment, negative for a refund.
+type LedgerEntry struct {
+	ID            string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ReservationID string    `gorm:"type:uuid;not null;index" json:"reservation_id"`
+	PaymentID     string    `gorm:"type:uuid;not null;index" json:"payment_id"`
+	Type          string    `gorm:"not null;check:type IN ('charge', 'refund')" json:"type"`
+	Amount        float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Method        string    `json:"method"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
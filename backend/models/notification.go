@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// In-app notification types
+const (
+	NotificationPaymentConfirmed = "payment_confirmed"
+	NotificationClassCancelled   = "class_cancelled"
+	NotificationWaitlistOffer    = "waitlist_offer" // for the waitlist; nothing posts it yet
+)
+
+// Notification is an entry in a user's in-app inbox
+type Notification struct {
+	ID        string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID    string     `gorm:"type:uuid;not null;index:idx_notifications_user_created" json:"user_id"`
+	Type      string     `gorm:"not null" json:"type"`
+	Title     string     `gorm:"not null" json:"title"`
+	Body      string     `json:"body"`
+	Data      []byte     `gorm:"type:jsonb" json:"-"`
+	DedupKey  *string    `gorm:"uniqueIndex" json:"-"` // event that created it, so retries add it once
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index:idx_notifications_user_created" json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification delivery statuses
+const (
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationSkipped = "skipped" // channel disabled or no address for the user
+)
+
+// NotificationDelivery logs one message sent, or not sent, over one channel.
+// DedupKey is unique so a retried event never sends the same message twice.
+type NotificationDelivery struct {
+	ID        string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID    string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	DedupKey  string     `gorm:"uniqueIndex;not null" json:"dedup_key"`
+	Template  string     `gorm:"not null" json:"template"`
+	Channel   string     `gorm:"not null" json:"channel"`
+	Locale    string     `json:"locale"`
+	Recipient string     `json:"recipient"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Status    string     `gorm:"not null;index" json:"status"`
+	Attempts  int        `gorm:"default:0" json:"attempts"`
+	LastError string     `json:"last_error"`
+	SentAt    *time.Time `json:"sent_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification channels
+const (
+	ChannelEmail    = "email"
+	ChannelWhatsApp = "whatsapp"
+)
+
+// NotificationPreference turns a delivery channel on or off for a user.
+// Channels without a row use the channel's default.
+type NotificationPreference struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID    string    `gorm:"type:uuid;not null;uniqueIndex:idx_notification_preferences_user_channel" json:"user_id"`
+	Channel   string    `gorm:"not null;uniqueIndex:idx_notification_preferences_user_channel" json:"channel"`
+	Enabled   bool      `gorm:"not null" json:"enabled"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// OperatingHours are the opening hours of one weekday. A row with CourtID
+// nil applies studio-wide; a court's own row for the weekday overrides it.
+// Weekdays without any row are not restricted.
+type OperatingHours struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	CourtID   *string   `gorm:"type:uuid;index" json:"court_id"`
+	Weekday   int       `gorm:"not null;check:weekday BETWEEN 0 AND 6" json:"weekday"` // 0 = Sunday
+	OpenTime  TimeOfDay `gorm:"type:time;not null" json:"open_time"`
+	CloseTime TimeOfDay `gorm:"type:time;not null" json:"close_time"`
+	Closed    bool      `gorm:"not null" json:"closed"` // closed all day
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
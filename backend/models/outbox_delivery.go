@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxDelivery records that one subscriber handled an outbox event, so a
+// retry only runs the subscribers that have not succeeded yet
+type OutboxDelivery struct {
+	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	EventID     string    `gorm:"type:uuid;not null;uniqueIndex:idx_outbox_deliveries_event_subscriber" json:"event_id"`
+	Subscriber  string    `gorm:"not null;uniqueIndex:idx_outbox_deliveries_event_subscriber" json:"subscriber"`
+	DeliveredAt time.Time `gorm:"not null" json:"delivered_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the state
+// change that caused it. The dispatcher delivers it to subscribers afterwards.
+type OutboxEvent struct {
+	ID            string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Type          string     `gorm:"not null;index" json:"type"`
+	AggregateID   string     `gorm:"not null;index" json:"aggregate_id"`
+	Payload       []byte     `gorm:"type:jsonb;not null" json:"-"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	DispatchedAt  *time.Time `gorm:"index" json:"dispatched_at"`
+	FailedAt      *time.Time `json:"failed_at"` // gave up after max attempts
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -4,12 +4,21 @@ import (
 	"time"
 )
 
+// Payment kinds. Only booking payments drive the reservation status;
+// adjustments (e.g. a reschedule top-up) are tracked separately.
+const (
+	PaymentKindBooking    = "booking"
+	PaymentKindAdjustment = "adjustment"
+)
+
 type Payment struct {
 	ID               string       `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	ReservationID    string       `gorm:"type:uuid;not null"`
 	Reservation      *Reservation `gorm:"constraint:OnDelete:CASCADE;"`
 	MidtransOrderID  string       `gorm:"uniqueIndex;not null"`
+	GatewayOrderID   *string      `gorm:"index"` // shared Snap order of a cart booking
 	Amount           float64      `gorm:"type:decimal(10,2);not null"`
+	Kind             string       `gorm:"default:'booking';not null"`
 	Status           string       `gorm:"default:'pending';check:status IN ('pending', 'success', 'failed', 'refunded')"`
 	PaymentMethod    string
 	TransactionTime  *time.Time
@@ -18,3 +27,11 @@ type Payment struct {
 	CreatedAt        time.Time `gorm:"autoCreateTime"`
 	UpdatedAt        time.Time `gorm:"autoUpdateTime"`
 }
+
+// OrderID is the order ID Midtrans knows this payment under
+func (p *Payment) OrderID() string {
+	if p.GatewayOrderID != nil && *p.GatewayOrderID != "" {
+		return *p.GatewayOrderID
+	}
+	return p.MidtransOrderID
+}
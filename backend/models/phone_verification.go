@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// PhoneVerification is a one-time code sent to a phone number the user wants
+// to use for messaging. The number is copied to the user once verified.
+type PhoneVerification struct {
+	ID         string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID     string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	Phone      string     `gorm:"not null" json:"phone"`
+	CodeHash   string     `gorm:"not null" json:"-"`
+	Attempts   int        `gorm:"default:0" json:"attempts"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -5,17 +5,21 @@ import (
 )
 
 type Reservation struct {
-	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	UserID      string    `gorm:"type:uuid;not null" json:"user_id"`
-	User        User      `gorm:"constraint:OnDelete:CASCADE;" json:"user"`
-	CourtID     string    `gorm:"type:uuid;not null" json:"court_id"`
-	Court       Court     `gorm:"constraint:OnDelete:CASCADE;" json:"court"`
-	ScheduleID  string    `gorm:"type:uuid;not null" json:"schedule_id"`
-	Schedule    Schedule  `gorm:"constraint:OnDelete:CASCADE;" json:"schedule"`
-	Status      string    `gorm:"default:'pending';check:status IN ('pending', 'confirmed', 'paid', 'cancelled', 'refunded')" json:"status"`
-	TotalAmount float64   `gorm:"type:decimal(10,2);not null" json:"total_amount"`
-	Notes       string    `json:"notes"`
-	Payment     *Payment  `gorm:"foreignKey:ReservationID" json:"payment"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID          string                `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID      string                `gorm:"type:uuid;not null" json:"user_id"`
+	User        User                  `gorm:"constraint:OnDelete:CASCADE;" json:"user"`
+	CourtID     string                `gorm:"type:uuid;not null" json:"court_id"`
+	Court       Court                 `gorm:"constraint:OnDelete:CASCADE;" json:"court"`
+	ScheduleID  string                `gorm:"type:uuid;not null" json:"schedule_id"`
+	Schedule    Schedule              `gorm:"constraint:OnDelete:CASCADE;" json:"schedule"`
+	BookingID   *string               `gorm:"type:uuid;index" json:"booking_id"`
+	Seats       int                   `gorm:"default:1;not null;check:seats > 0" json:"seats"`
+	Status      string                `gorm:"default:'pending';check:status IN ('pending', 'confirmed', 'paid', 'cancelled', 'refunded')" json:"status"`
+	TotalAmount float64               `gorm:"type:decimal(10,2);not null" json:"total_amount"`
+	Notes       string                `json:"notes"`
+	CheckedInAt *time.Time            `json:"checked_in_at"`
+	Payment     *Payment              `gorm:"foreignKey:ReservationID" json:"payment"`
+	Attendees   []ReservationAttendee `gorm:"foreignKey:ReservationID" json:"attendees,omitempty"`
+	CreatedAt   time.Time             `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time             `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Waiver statuses of an attendee
+const (
+	WaiverPending = "pending"
+	WaiverSigned  = "signed"
+)
+
+// ReservationAttendee is a named person occupying one seat of a reservation.
+// A guest claims the seat into their own account with the emailed token.
+type ReservationAttendee struct {
+	ID                  string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ReservationID       string     `gorm:"type:uuid;not null;index" json:"reservation_id"`
+	Name                string     `gorm:"not null" json:"name"`
+	Email               string     `gorm:"not null" json:"email"`
+	Phone               *string    `json:"phone"`
+	WaiverStatus        string     `gorm:"default:'pending';not null;check:waiver_status IN ('pending', 'signed')" json:"waiver_status"`
+	WaiverSignedAt      *time.Time `json:"waiver_signed_at"`
+	ClaimTokenHash      string     `gorm:"index" json:"-"`
+	ClaimTokenExpiresAt *time.Time `json:"-"`
+	UserID              *string    `gorm:"type:uuid;index" json:"user_id"` // set once claimed
+	ClaimedAt           *time.Time `json:"claimed_at"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
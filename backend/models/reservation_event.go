@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// ReservationEvent is one entry of a reservation's timeline. Rows are only
+// ever inserted; a trigger (domain.EnsureTimelineAppendOnly) rejects updates
+// and deletes except those made by the foreign key actions below.
+type ReservationEvent struct {
+	ID             string       `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ReservationID  string       `gorm:"type:uuid;not null;index" json:"reservation_id"`
+	Reservation    *Reservation `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Type           string       `gorm:"not null" json:"type"` // created, status_changed, payment_status_changed, payment_partially_refunded, rescheduled
+	FromStatus     string       `json:"from_status,omitempty"`
+	ToStatus       string       `json:"to_status,omitempty"`
+	ActorID        *string      `gorm:"type:uuid" json:"actor_id"`
+	Actor          *User        `gorm:"foreignKey:ActorID;constraint:OnDelete:SET NULL;" json:"-"`
+	ImpersonatorID *string      `gorm:"type:uuid" json:"impersonator_id,omitempty"`
+	Impersonator   *User        `gorm:"foreignKey:ImpersonatorID;constraint:OnDelete:SET NULL;" json:"-"`
+	Source         string       `gorm:"not null" json:"source"`
+	Payload        []byte       `gorm:"type:jsonb" json:"payload,omitempty"`
+	CreatedAt      time.Time    `gorm:"autoCreateTime;index" json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Refund outcomes of a reschedule to a cheaper slot
+const (
+	RescheduleRefundNone     = ""
+	RescheduleRefundDone     = "refunded"
+	RescheduleRefundFailed   = "failed"
+	RescheduleRefundManual   = "manual" // cash payments are refunded at the front desk
+	RescheduleRefundRequired = "required"
+)
+
+// ReservationReschedule records each move of a reservation to another schedule.
+// A move to a pricier slot is reverted if its top-up payment fails or expires;
+// when that is no longer possible the move is flagged for an admin to review.
+type ReservationReschedule struct {
+	ID                  string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ReservationID       string     `gorm:"type:uuid;not null;index" json:"reservation_id"`
+	FromScheduleID      string     `gorm:"type:uuid;not null" json:"from_schedule_id"`
+	ToScheduleID        string     `gorm:"type:uuid;not null" json:"to_schedule_id"`
+	PriceDifference     float64    `gorm:"type:decimal(10,2);not null;default:0" json:"price_difference"`
+	AdjustmentPaymentID *string    `gorm:"type:uuid" json:"adjustment_payment_id"`
+	RefundStatus        string     `json:"refund_status"`
+	ActorID             string     `gorm:"type:uuid;not null" json:"actor_id"`
+	RevertedAt          *time.Time `json:"reverted_at"`     // set when an unpaid top-up undid the move
+	NeedsReviewAt       *time.Time `json:"needs_review_at"` // set when an unpaid top-up could not undo the move
+	ReviewNote          string     `json:"review_note"`
+	ReviewedAt          *time.Time `json:"reviewed_at"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
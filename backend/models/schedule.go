@@ -1,17 +1,59 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type Schedule struct {
-	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	CourtID     string    `gorm:"type:uuid;not null" json:"court_id"`
-	Court       Court     `gorm:"constraint:OnDelete:CASCADE;" json:"court"`
-	Date        time.Time `gorm:"type:date;not null" json:"date"`
-	StartTime   string    `gorm:"type:varchar(10);not null" json:"start_time"`
-	EndTime     string    `gorm:"type:varchar(10);not null" json:"end_time"`
-	IsAvailable bool      `gorm:"default:true" json:"is_available"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID          string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	CourtID     string     `gorm:"type:uuid;not null" json:"court_id"`
+	Court       Court      `gorm:"constraint:OnDelete:CASCADE;" json:"court"`
+	Date        time.Time  `gorm:"type:date;not null" json:"date"`
+	StartTime   TimeOfDay  `gorm:"type:time;not null" json:"start_time"` // studio local time
+	EndTime     TimeOfDay  `gorm:"type:time;not null" json:"end_time"`
+	IsAvailable bool       `gorm:"default:true" json:"is_available"`
+	IsPrivate   bool       `gorm:"default:false" json:"is_private"`    // created for an approved session request, never bookable publicly
+	Price       *float64   `gorm:"type:decimal(10,2)" json:"price"`    // overrides the court's price per slot when set
+	Capacity    *int       `gorm:"check:capacity > 0" json:"capacity"` // overrides the court's capacity when set
+	ReleasedAt  *time.Time `json:"released_at"`                        // a private session whose reservation ended; no longer holds the court
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// StartsAt is the absolute start of the session
+func (s Schedule) StartsAt() time.Time {
+	return s.StartTime.On(s.Date)
+}
+
+// EndsAt is the absolute end of the session
+func (s Schedule) EndsAt() time.Time {
+	return s.EndTime.On(s.Date)
+}
+
+// SlotPrice is the price of one seat, the schedule's own price or the court's
+func (s Schedule) SlotPrice(court Court) float64 {
+	if s.Price != nil {
+		return *s.Price
+	}
+	return court.PricePerSlot
+}
+
+// SeatCapacity is the number of seats, the schedule's own capacity or the court's
+func (s Schedule) SeatCapacity(court Court) int {
+	if s.Capacity != nil {
+		return *s.Capacity
+	}
+	return court.Capacity
+}
+
+// MarshalJSON adds the absolute starts_at and ends_at so clients do not have
+// to combine date and time in the studio timezone themselves
+func (s Schedule) MarshalJSON() ([]byte, error) {
+	type schedule Schedule
+	return json.Marshal(struct {
+		schedule
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at"`
+	}{schedule(s), s.StartsAt(), s.EndsAt()})
 }
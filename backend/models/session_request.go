@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Private session request statuses
+const (
+	SessionRequestPending   = "pending"
+	SessionRequestApproved  = "approved"
+	SessionRequestDeclined  = "declined"
+	SessionRequestExpired   = "expired"
+	SessionRequestCancelled = "cancelled"
+)
+
+// SessionRequest is a customer's proposal for a one-on-one session outside
+// the published schedule. Approving it creates a private Schedule and a
+// pending Reservation for the requester.
+type SessionRequest struct {
+	ID                  string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID              string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	User                User       `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	CourtID             *string    `gorm:"type:uuid" json:"court_id"`
+	Court               *Court     `json:"court,omitempty"`
+	PreferredInstructor string     `json:"preferred_instructor"`
+	ClassType           string     `gorm:"not null" json:"class_type"`
+	Date                time.Time  `gorm:"type:date;not null" json:"date"`
+	StartTime           TimeOfDay  `gorm:"type:time;not null" json:"start_time"`
+	EndTime             TimeOfDay  `gorm:"type:time;not null" json:"end_time"`
+	Notes               string     `json:"notes"`
+	Status              string     `gorm:"default:'pending';not null;index;check:status IN ('pending', 'approved', 'declined', 'expired', 'cancelled')" json:"status"`
+	DeclineReason       string     `json:"decline_reason,omitempty"`
+	ScheduleID          *string    `gorm:"type:uuid" json:"schedule_id"`
+	ReservationID       *string    `gorm:"type:uuid" json:"reservation_id"`
+	SnapToken           string     `json:"snap_token,omitempty"`
+	PaymentRedirectURL  string     `json:"payment_redirect_url,omitempty"`
+	ReviewedBy          *string    `gorm:"type:uuid" json:"reviewed_by"`
+	ReviewedAt          *time.Time `json:"reviewed_at"`
+	ExpiresAt           time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+// TimeOfDay is a wall-clock time in the studio timezone, stored in a
+// Postgres TIME column and written as "HH:MM" in JSON. The value is the
+// number of seconds after midnight, so times compare with < and >.
+type TimeOfDay int
+
+// NewTimeOfDay returns hour:minute
+func NewTimeOfDay(hour, minute int) TimeOfDay {
+	return TimeOfDay(hour*3600 + minute*60)
+}
+
+// ParseTimeOfDay accepts HH:MM and HH:MM:SS
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return TimeOfDay(t.Hour()*3600 + t.Minute()*60 + t.Second()), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+}
+
+func (t TimeOfDay) Hour() int   { return int(t) / 3600 }
+func (t TimeOfDay) Minute() int { return int(t) % 3600 / 60 }
+func (t TimeOfDay) Second() int { return int(t) % 60 }
+
+// String is HH:MM, or HH:MM:SS when seconds are set
+func (t TimeOfDay) String() string {
+	if t.Second() != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", t.Hour(), t.Minute(), t.Second())
+	}
+	return fmt.Sprintf("%02d:%02d", t.Hour(), t.Minute())
+}
+
+// Add returns the time d later, without wrapping past midnight
+func (t TimeOfDay) Add(d time.Duration) TimeOfDay {
+	return t + TimeOfDay(d/time.Second)
+}
+
+// On returns the absolute time at t on the studio calendar date of date
+func (t TimeOfDay) On(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, utils.StudioLocation())
+}
+
+func (TimeOfDay) GormDataType() string {
+	return "time"
+}
+
+func (t TimeOfDay) Value() (driver.Value, error) {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour(), t.Minute(), t.Second()), nil
+}
+
+func (t *TimeOfDay) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = TimeOfDay(v.Hour()*3600 + v.Minute()*60 + v.Second())
+		return nil
+	case []byte:
+		return t.scanString(string(v))
+	case string:
+		return t.scanString(v)
+	}
+	return fmt.Errorf("cannot scan %T into TimeOfDay", src)
+}
+
+func (t *TimeOfDay) scanString(s string) error {
+	// Postgres may append fractional seconds
+	if len(s) > 8 {
+		s = s[:8]
+	}
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
@@ -8,10 +8,25 @@ type User struct {
 	ID            string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	Name          string
 	Email         string `gorm:"uniqueIndex;not null"`
-	PasswordHash  string `gorm:"not null"`
+	PasswordHash  string `gorm:"not null" json:"-"`
 	Role          string `gorm:"default:'user'"`
 	EmailVerified *time.Time
 	Image         *string
-	CreatedAt     time.Time `gorm:"autoCreateTime"`
-	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	// Contact details and secrets are left out of JSON so they never leak
+	// through nested User objects (e.g. a reservation's user); the profile
+	// endpoints return them explicitly
+	Phone *string `json:"-"`
+	// PhoneVerifiedAt is set once the phone number is confirmed by OTP; required for WhatsApp
+	PhoneVerifiedAt *time.Time `json:"-"`
+	Locale          string     `gorm:"default:'id';not null"` // language of notifications: id, en
+	// CalendarTokenHash authenticates the personal ICS feed; rotating it revokes old subscriptions
+	CalendarTokenHash *string `gorm:"uniqueIndex" json:"-"`
+	// Emergency contact shown to instructors during a session
+	EmergencyContactName  *string `json:"-"`
+	EmergencyContactPhone *string `json:"-"`
+	// AnonymizedAt is set when the account is deleted; the row is kept so
+	// reservations and payments stay intact for accounting
+	AnonymizedAt *time.Time
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
 }
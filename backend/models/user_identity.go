@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links an external OpenID Connect account to a local user
+type UserIdentity struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID    string    `gorm:"type:uuid;not null;index" json:"user_id"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
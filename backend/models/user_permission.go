@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Permissions that are granted per user through user_permissions rather
+// than implied by the role.
+const (
+	PermImpersonateUsers = "users:impersonate"
+)
+
+// UserPermission grants an extra permission to a single user on top of the
+// defaults implied by their role.
+type UserPermission struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID     string    `gorm:"type:uuid;not null;uniqueIndex:idx_user_permission" json:"user_id"`
+	User       User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Permission string    `gorm:"not null;uniqueIndex:idx_user_permission" json:"permission"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
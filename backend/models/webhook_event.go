@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Processing states of an inbox entry
+const (
+	WebhookReceived  = "received"
+	WebhookProcessed = "processed"
+	WebhookIgnored   = "ignored"
+	WebhookFailed    = "failed"
+)
+
+// WebhookEvent is a verified payment notification kept in the inbox so it
+// can be deduplicated, inspected and replayed.
+type WebhookEvent struct {
+	ID                string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Source            string     `gorm:"not null;default:'midtrans'" json:"source"`
+	DedupKey          string     `gorm:"uniqueIndex;not null" json:"dedup_key"`
+	OrderID           string     `gorm:"index;not null" json:"order_id"`
+	TransactionID     string     `json:"transaction_id"`
+	TransactionStatus string     `json:"transaction_status"`
+	FraudStatus       string     `json:"fraud_status"`
+	Payload           []byte     `gorm:"type:jsonb" json:"-"`
+	Status            string     `gorm:"default:'received';check:status IN ('received', 'processed', 'ignored', 'failed')" json:"status"`
+	Note              string     `json:"note"`
+	Attempts          int        `gorm:"default:0" json:"attempts"`
+	ProcessedAt       *time.Time `json:"processed_at"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// WebhookSubscription is an admin-configured endpoint that receives signed
+// domain events (accounting, CRM, ...)
+type WebhookSubscription struct {
+	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	URL         string    `gorm:"not null" json:"url"`
+	Description string    `json:"description"`
+	EventTypes  string    `gorm:"not null" json:"-"` // comma separated
+	Secret      string    `gorm:"not null" json:"-"` // HMAC-SHA256 signing secret
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (s *WebhookSubscription) EventTypeList() []string {
+	if s.EventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(s.EventTypes, ",")
+}
+
+func (s *WebhookSubscription) Wants(eventType string) bool {
+	for _, t := range s.EventTypeList() {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the delivery of one event to one subscription. It holds
+// the current state and last response; every attempt is kept as a
+// WebhookDeliveryAttempt.
+type WebhookDelivery struct {
+	ID             string               `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	SubscriptionID string               `gorm:"type:uuid;not null;uniqueIndex:idx_delivery_subscription_event" json:"subscription_id"`
+	Subscription   *WebhookSubscription `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	EventID        string               `gorm:"not null;uniqueIndex:idx_delivery_subscription_event" json:"event_id"`
+	EventType      string               `gorm:"not null" json:"event_type"`
+	Payload        []byte               `gorm:"type:jsonb;not null" json:"-"`
+	Status         string               `gorm:"default:'pending';index;check:status IN ('pending', 'succeeded', 'failed')" json:"status"`
+	Attempts       int                  `gorm:"default:0" json:"attempts"`   // all attempts, never reset
+	RetryBase      int                  `gorm:"default:0" json:"retry_base"` // Attempts when the current round of retries started (see Redeliver)
+	NextAttemptAt  time.Time            `gorm:"not null;index" json:"next_attempt_at"`
+	ResponseStatus int                  `json:"response_status"`
+	ResponseBody   string               `json:"response_body"`
+	LastError      string               `json:"last_error"`
+	DeliveredAt    *time.Time           `json:"delivered_at"`
+	CreatedAt      time.Time            `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time            `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WebhookDeliveryAttempt records one HTTP attempt of a delivery
+type WebhookDeliveryAttempt struct {
+	ID             string           `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	DeliveryID     string           `gorm:"type:uuid;not null;index" json:"delivery_id"`
+	Delivery       *WebhookDelivery `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Attempt        int              `gorm:"not null" json:"attempt"` // 1-based, counts across redeliveries
+	ResponseStatus int              `json:"response_status"`
+	ResponseBody   string           `json:"response_body"`
+	Error          string           `json:"error"`
+	DurationMs     int64            `json:"duration_ms"`
+	AttemptedAt    time.Time        `gorm:"not null" json:"attempted_at"`
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/Giriathallah/diro-pilates-backend/controllers"
 	"github.com/Giriathallah/diro-pilates-backend/middleware"
+	"github.com/Giriathallah/diro-pilates-backend/models"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
@@ -15,18 +16,27 @@ func SetupAdminRoutes(
 	courtController *controllers.CourtController,
 	scheduleController *controllers.ScheduleController,
 	resController *controllers.ReservationController,
+	impersonationController *controllers.ImpersonationController,
+	apiKeyController *controllers.APIKeyController,
+	webhookSubController *controllers.WebhookSubscriptionController,
+	attendeeController *controllers.AttendeeController,
+	sessionRequestController *controllers.SessionRequestController,
+	notificationController *controllers.NotificationController,
+	studioCalendarController *controllers.StudioCalendarController,
+	blackoutController *controllers.BlackoutController,
 ) {
 	// Group routes
 	admin := app.Group("/api/admin")
 
 	// Apply Auth and Role Middleware
 	// We should add a generic Role check middleware, but for now we can rely on Auth + check inside controller OR simplistic role middleware
-	admin.Use(middleware.Protected())
+	admin.Use(middleware.Protected(db))
 	admin.Use(middleware.AdminOnly()) // Need to implement this
 
 	// Stats
 	admin.Get("/stats", adminController.GetDashboardStats)
-	admin.Post("/manual-booking", adminController.CreateManualReservation)
+	admin.Get("/agenda/stream", adminController.StreamAgenda)
+	admin.Post("/manual-booking", middleware.Idempotency(db), adminController.CreateManualReservation)
 
 	// Courts
 	admin.Get("/courts", courtController.GetAllCourts)
@@ -37,9 +47,68 @@ func SetupAdminRoutes(
 	// Schedules
 	admin.Get("/schedules", scheduleController.GetAdminSchedules)
 	admin.Post("/schedules/bulk", scheduleController.CreateScheduleBulk)
+	admin.Post("/schedules/bulk/copy-week", scheduleController.CopyScheduleWeek)
+	admin.Post("/schedules/bulk/delete", scheduleController.DeleteScheduleRange)
+	admin.Post("/schedules/bulk/close", scheduleController.CloseScheduleRange)
+	admin.Post("/schedules/bulk/shift", scheduleController.ShiftScheduleRange)
+	admin.Post("/schedules/bulk/update", scheduleController.UpdateScheduleRange)
 	admin.Put("/schedules/:id", scheduleController.UpdateSchedule)
 
+	// Operating hours, holidays and blackouts
+	admin.Get("/operating-hours", studioCalendarController.GetOperatingHours)
+	admin.Put("/operating-hours", studioCalendarController.UpdateOperatingHours)
+	admin.Get("/holidays", studioCalendarController.GetHolidays)
+	admin.Post("/holidays", studioCalendarController.CreateHoliday)
+	admin.Post("/holidays/import", studioCalendarController.ImportHolidays)
+	admin.Delete("/holidays/:id", studioCalendarController.DeleteHoliday)
+	admin.Get("/blackouts", blackoutController.GetBlackouts)
+	admin.Post("/blackouts", blackoutController.CreateBlackout)
+	admin.Get("/blackouts/:id/conflicts", blackoutController.GetBlackoutConflicts)
+	admin.Post("/blackouts/:id/cancel-reservations", blackoutController.CancelBlackoutReservations)
+	admin.Delete("/blackouts/:id", blackoutController.DeleteBlackout)
+
 	// Reservations
 	admin.Get("/reservations", resController.GetAllReservations)
 	admin.Post("/reservations/:id/cancel", resController.AdminCancelReservation)
+	admin.Post("/reservations/:id/check-in", resController.AdminCheckInReservation)
+	admin.Get("/reservations/:id/timeline", resController.AdminGetReservationTimeline)
+	admin.Get("/reschedules/review", resController.AdminGetReschedulesForReview)
+	admin.Post("/reschedules/:id/retry-refund", resController.AdminRetryRescheduleRefund)
+	admin.Post("/reschedules/:id/resolve", resController.AdminResolveReschedule)
+	admin.Put("/attendees/:id/waiver", attendeeController.AdminUpdateWaiver)
+
+	// Private session requests
+	admin.Get("/session-requests", sessionRequestController.GetSessionRequests)
+	admin.Post("/session-requests/:id/approve", sessionRequestController.ApproveSessionRequest)
+	admin.Post("/session-requests/:id/decline", sessionRequestController.DeclineSessionRequest)
+
+	// Notifications
+	admin.Get("/notifications/deliveries", notificationController.GetDeliveries)
+
+	// Midtrans webhook inbox
+	admin.Get("/webhooks", resController.GetWebhookEvents)
+	admin.Get("/webhooks/:id", resController.GetWebhookEvent)
+	admin.Post("/webhooks/:id/replay", resController.ReplayWebhookEvent)
+
+	// Outbound webhooks (accounting, CRM)
+	admin.Get("/outbound-webhooks", webhookSubController.GetSubscriptions)
+	admin.Post("/outbound-webhooks", webhookSubController.CreateSubscription)
+	admin.Put("/outbound-webhooks/:id", webhookSubController.UpdateSubscription)
+	admin.Delete("/outbound-webhooks/:id", webhookSubController.DeleteSubscription)
+	admin.Get("/outbound-webhooks/:id/deliveries", webhookSubController.GetDeliveries)
+	admin.Get("/outbound-webhooks/:id/deliveries/:deliveryId/attempts", webhookSubController.GetDeliveryAttempts)
+	admin.Post("/outbound-webhooks/:id/deliveries/:deliveryId/redeliver", webhookSubController.Redeliver)
+
+	// Impersonation & Audit
+	admin.Post("/users/:id/impersonate",
+		middleware.NoImpersonation(),
+		middleware.RequirePermission(db, models.PermImpersonateUsers),
+		impersonationController.StartImpersonation,
+	)
+	admin.Get("/audit-logs", impersonationController.GetAuditLogs)
+
+	// API Keys (partner & kiosk integrations)
+	admin.Get("/api-keys", apiKeyController.GetAPIKeys)
+	admin.Post("/api-keys", apiKeyController.CreateAPIKey)
+	admin.Delete("/api-keys/:id", apiKeyController.RevokeAPIKey)
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/controllers"
+	"github.com/Giriathallah/diro-pilates-backend/middleware"
+)
+
+func SetupAttendeeRoutes(app *fiber.App, db *gorm.DB, attendeeController *controllers.AttendeeController) {
+	// Booker manages the named attendees of a reservation
+	reservation := app.Group("/api/reservations/:id/attendees", middleware.Protected(db))
+	reservation.Get("/", attendeeController.GetAttendees)
+	reservation.Post("/", attendeeController.AddAttendee)
+	reservation.Delete("/:attendeeId", attendeeController.RemoveAttendee)
+	reservation.Post("/:attendeeId/resend", attendeeController.ResendClaimLink)
+
+	// Guests claim their seat
+	attendees := app.Group("/api/attendees", middleware.Protected(db))
+	attendees.Post("/claim", attendeeController.ClaimSeat)
+	attendees.Get("/my", attendeeController.GetMySeats)
+	attendees.Post("/:id/waiver", attendeeController.SignWaiver)
+}
@@ -6,10 +6,13 @@ import (
 
 	"github.com/Giriathallah/diro-pilates-backend/controllers"
 	"github.com/Giriathallah/diro-pilates-backend/middleware"
+	"github.com/Giriathallah/diro-pilates-backend/services"
 )
 
-func SetupAuthRoutes(app *fiber.App, db *gorm.DB) {
+func SetupAuthRoutes(app *fiber.App, db *gorm.DB, oidc *services.OIDCService) {
 	authController := controllers.NewAuthController(db)
+	impersonationController := controllers.NewImpersonationController(db)
+	oidcController := controllers.NewOIDCController(db, oidc)
 
 	// Group utama /api
 	api := app.Group("/api")
@@ -20,6 +23,14 @@ func SetupAuthRoutes(app *fiber.App, db *gorm.DB) {
 	auth.Post("/login", authController.Login)
 	auth.Post("/logout", authController.Logout)
 
+	// Social login (OpenID Connect)
+	auth.Get("/oidc/:provider/login", oidcController.Login)
+	auth.Get("/oidc/:provider/callback", oidcController.Callback)
+
 	// Protected routes
-	auth.Get("/me", middleware.Protected(), authController.Me)
+	auth.Get("/me", middleware.Protected(db), authController.Me)
+	auth.Post("/impersonation/stop", middleware.Protected(db), impersonationController.StopImpersonation)
+	auth.Get("/oidc/:provider/link", middleware.Protected(db), middleware.NoImpersonation(), oidcController.Link)
+	auth.Get("/identities", middleware.Protected(db), oidcController.GetIdentities)
+	auth.Delete("/identities/:id", middleware.Protected(db), middleware.NoImpersonation(), oidcController.UnlinkIdentity)
 }
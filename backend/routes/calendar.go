@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/controllers"
+	"github.com/Giriathallah/diro-pilates-backend/middleware"
+)
+
+func SetupCalendarRoutes(app *fiber.App, db *gorm.DB, calendarController *controllers.CalendarController) {
+	calendar := app.Group("/api/calendar")
+
+	// Public: the studio schedule, and personal feeds authenticated by their URL token
+	calendar.Get("/studio.ics", calendarController.GetStudioFeed)
+	calendar.Get("/feed/:token", calendarController.GetUserFeed)
+
+	// The feed token is a credential, so it cannot be issued while impersonating
+	calendar.Post("/token", middleware.Protected(db), middleware.NoImpersonation(), calendarController.CreateCalendarToken)
+	calendar.Delete("/token", middleware.Protected(db), middleware.NoImpersonation(), calendarController.RevokeCalendarToken)
+
+	app.Get("/api/reservations/:id/calendar.ics", middleware.Protected(db), calendarController.DownloadReservation)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/controllers"
+	"github.com/Giriathallah/diro-pilates-backend/middleware"
+)
+
+func SetupNotificationRoutes(app *fiber.App, db *gorm.DB, notificationController *controllers.NotificationController) {
+	notifications := app.Group("/api/notifications", middleware.Protected(db))
+	notifications.Get("/preferences", notificationController.GetPreferences)
+	notifications.Put("/preferences", notificationController.UpdatePreferences)
+
+	// In-app inbox
+	notifications.Get("/", notificationController.GetNotifications)
+	notifications.Post("/read-all", notificationController.MarkAllNotificationsRead)
+	notifications.Post("/:id/read", notificationController.MarkNotificationRead)
+}
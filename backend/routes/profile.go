@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/controllers"
+	"github.com/Giriathallah/diro-pilates-backend/middleware"
+	"github.com/Giriathallah/diro-pilates-backend/services"
+)
+
+func SetupProfileRoutes(app *fiber.App, db *gorm.DB, messaging services.MessagingProvider) {
+	profileController := controllers.NewProfileController(db, messaging)
+
+	profile := app.Group("/api/profile", middleware.Protected(db))
+	profile.Get("/", profileController.GetProfile)
+	profile.Put("/", profileController.UpdateProfile)
+	profile.Get("/export", profileController.ExportMyData)
+	profile.Post("/phone", profileController.RequestPhoneVerification)
+	profile.Post("/phone/verify", profileController.VerifyPhone)
+
+	// Sensitive operations are blocked while an admin impersonates the user
+	profile.Put("/password", middleware.NoImpersonation(), profileController.ChangePassword)
+	profile.Delete("/", middleware.NoImpersonation(), profileController.DeleteAccount)
+}
@@ -26,9 +26,13 @@ func SetupReservationRoutes(app *fiber.App, db *gorm.DB, mt *services.MidtransSe
 	api.Post("/midtrans/webhook", resController.HandleMidtransNotification)
 
 	// Protected routes
-	reservation := api.Group("/reservations", middleware.Protected())
+	reservation := api.Group("/reservations", middleware.Protected(db))
 	reservation.Get("/my", resController.GetMyReservations)
-	reservation.Post("/", resController.CreateReservation)
+	reservation.Post("/", middleware.Idempotency(db), resController.CreateReservation)
+	reservation.Post("/cart", middleware.Idempotency(db), resController.CreateCartReservation)
 	reservation.Post("/:id/mark-paid", resController.MarkReservationAsPaid)
 	reservation.Post("/:id/cancel", resController.CancelReservation)
+	reservation.Post("/:id/reschedule", middleware.Idempotency(db), resController.RescheduleReservation)
+	reservation.Get("/:id/reschedules", resController.GetReschedules)
+	reservation.Get("/:id/timeline", resController.GetReservationTimeline)
 }
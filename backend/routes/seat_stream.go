@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Giriathallah/diro-pilates-backend/controllers"
+)
+
+func SetupSeatStreamRoutes(app *fiber.App, seatStreamController *controllers.SeatStreamController) {
+	// Public, like /api/schedules: seat counts carry no personal data
+	app.Get("/api/schedules/stream", seatStreamController.StreamSeats)
+}
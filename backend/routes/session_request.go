@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/controllers"
+	"github.com/Giriathallah/diro-pilates-backend/middleware"
+)
+
+func SetupSessionRequestRoutes(app *fiber.App, db *gorm.DB, sessionRequestController *controllers.SessionRequestController) {
+	requests := app.Group("/api/session-requests", middleware.Protected(db))
+	requests.Post("/", sessionRequestController.CreateSessionRequest)
+	requests.Get("/my", sessionRequestController.GetMySessionRequests)
+	requests.Get("/:id", sessionRequestController.GetSessionRequest)
+	requests.Post("/:id/cancel", sessionRequestController.CancelSessionRequest)
+}
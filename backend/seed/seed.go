@@ -2,12 +2,12 @@ package seed
 
 import (
 	"log"
-	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
 )
 
 func Run(db *gorm.DB) {
@@ -34,6 +34,9 @@ func Run(db *gorm.DB) {
 			log.Printf("Failed to seed admin user: %v", err)
 		} else {
 			log.Println("Admin user seeded successfully: admin@gmail.com / asdasdasd")
+
+			// Grant support permissions to the seeded admin
+			db.Create(&models.UserPermission{UserID: admin.ID, Permission: models.PermImpersonateUsers})
 		}
 	} else {
 		log.Println("Admin user already exists, skipping...")
@@ -77,7 +80,7 @@ func Run(db *gorm.DB) {
 			return
 		}
 
-		today := time.Now().Truncate(24 * time.Hour) // truncate ke midnight hari ini
+		today := utils.StudioToday() // midnight hari ini di zona waktu studio
 
 		for i := 0; i < 3; i++ { // 3 hari ke depan
 			date := today.AddDate(0, 0, i)
@@ -87,30 +90,30 @@ func Run(db *gorm.DB) {
 					{
 						CourtID:     court.ID,
 						Date:        date,
-						StartTime:   "09:00:00",
-						EndTime:     "10:00:00",
+						StartTime:   models.NewTimeOfDay(9, 0),
+						EndTime:     models.NewTimeOfDay(10, 0),
 						IsAvailable: true,
 					},
 					{
 						CourtID:     court.ID,
 						Date:        date,
-						StartTime:   "10:00:00",
-						EndTime:     "11:00:00",
+						StartTime:   models.NewTimeOfDay(10, 0),
+						EndTime:     models.NewTimeOfDay(11, 0),
 						IsAvailable: true,
 					},
 					{
 						CourtID:     court.ID,
 						Date:        date,
-						StartTime:   "11:00:00",
-						EndTime:     "12:00:00",
+						StartTime:   models.NewTimeOfDay(11, 0),
+						EndTime:     models.NewTimeOfDay(12, 0),
 						IsAvailable: true,
 					},
 					// Bisa tambah slot sore/malam jika mau lebih realistis
 					// {
 					// 	CourtID:     court.ID,
 					// 	Date:        date,
-					// 	StartTime:   "16:00:00",
-					// 	EndTime:     "17:00:00",
+					// 	StartTime:   models.NewTimeOfDay(16, 0),
+					// 	EndTime:     models.NewTimeOfDay(17, 0),
 					// 	IsAvailable: true,
 					// },
 				}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+const (
+	adjustmentExpiryInterval = 5 * time.Minute
+	// Top-up Snap pages expire after 15 minutes; the margin lets a late
+	// notification arrive first
+	adjustmentPaymentTTL = 45 * time.Minute
+)
+
+// StartAdjustmentPaymentExpiry periodically fails top-up payments that were
+// never paid, which moves their reservations back, until ctx is cancelled
+func StartAdjustmentPaymentExpiry(ctx context.Context, db *gorm.DB, mt *MidtransService) {
+	go func() {
+		ticker := time.NewTicker(adjustmentExpiryInterval)
+		defer ticker.Stop()
+
+		for {
+			ExpireAdjustmentPayments(db, mt)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ExpireAdjustmentPayments settles pending top-ups past their deadline with
+// the status Midtrans reports, or as failed when no transaction was ever made
+func ExpireAdjustmentPayments(db *gorm.DB, mt *MidtransService) {
+	var payments []models.Payment
+	if err := db.Where("kind = ? AND status = ? AND created_at < ?",
+		models.PaymentKindAdjustment, domain.PaymentPending, time.Now().Add(-adjustmentPaymentTTL)).
+		Find(&payments).Error; err != nil {
+		log.Printf("Adjustment payment expiry failed: %v", err)
+		return
+	}
+
+	for i := range payments {
+		settleStalePayment(db, mt, &payments[i], nil)
+	}
+}
+
+// settleStalePayment applies the status Midtrans reports for a payment that
+// is still pending past its deadline, or fails it when no transaction was ever
+// made. after runs in the same transaction once the status is applied.
+func settleStalePayment(db *gorm.DB, mt *MidtransService, payment *models.Payment, after func(tx *gorm.DB, status string) error) {
+	status := domain.PaymentFailed
+	resp, err := mt.VerifyTransaction(payment.MidtransOrderID)
+	if err != nil && !IsTransactionNotFound(err) {
+		log.Printf("Payment %s: could not check Midtrans: %v", payment.ID, err)
+		return
+	}
+	if err == nil {
+		switch s := PaymentStatusFromMidtrans(resp.TransactionStatus, resp.FraudStatus); s {
+		case domain.PaymentSuccess, domain.PaymentFailed:
+			status = s
+		}
+	}
+
+	tx := domain.WithActor(db.Begin(), domain.Actor{Source: domain.SourceSystem})
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(payment, "id = ? AND status = ?", payment.ID, domain.PaymentPending).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err := domain.ApplyPaymentStatus(tx, payment, status); err != nil {
+		tx.Rollback()
+		log.Printf("Payment %s: %v", payment.ID, err)
+		return
+	}
+	if after != nil {
+		if err := after(tx, status); err != nil {
+			tx.Rollback()
+			log.Printf("Payment %s: %v", payment.ID, err)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Payment %s: %v", payment.ID, err)
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+const agendaHistorySize = 500
+
+// Agenda delta types
+const (
+	AgendaBookingCreated  = "booking_created"
+	AgendaPaymentReceived = "payment_received"
+	AgendaCancelled       = "cancellation"
+	AgendaCheckedIn       = "check_in"
+	AgendaRescheduled     = "rescheduled"
+	AgendaScheduleChanged = "schedule_changed"
+	AgendaScheduleRemoved = "schedule_removed" // Item only carries the schedule ID and date
+)
+
+// AgendaEvents are the event types pushed to the live admin agenda
+var AgendaEvents = []string{
+	events.ReservationCreated,
+	events.ReservationPaid,
+	events.ReservationCancelled,
+	events.ReservationRefunded,
+	events.ReservationCheckedIn,
+	events.ReservationRescheduled,
+	events.ScheduleChanged,
+}
+
+// AgendaAttendee is a named attendee shown on an agenda row
+type AgendaAttendee struct {
+	Name          string `json:"name"`
+	ReservationID string `json:"reservation_id"`
+	WaiverStatus  string `json:"waiver_status"`
+	Claimed       bool   `json:"claimed"`
+}
+
+// AgendaBooking is a reservation as shown on an agenda row. It carries only
+// the booker's name, never the rest of their account.
+type AgendaBooking struct {
+	ReservationID string `json:"reservation_id"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	Seats         int    `json:"seats"`
+}
+
+// AgendaItem is one schedule on the admin dashboard agenda
+type AgendaItem struct {
+	ScheduleID  string           `json:"schedule_id"`
+	Date        string           `json:"date"`
+	CourtName   string           `json:"court_name"`
+	Time        string           `json:"time"`
+	Status      string           `json:"status"`
+	StatusColor string           `json:"status_color"` // green, yellow, blue, red, grey
+	Customer    string           `json:"customer"`
+	Bookings    []AgendaBooking  `json:"bookings"`
+	SeatsBooked int              `json:"seats_booked"`
+	CheckedIn   int              `json:"checked_in"`
+	Attendees   []AgendaAttendee `json:"attendees"`
+	IsAvailable bool             `json:"is_available"`
+}
+
+// BuildAgenda returns the agenda rows for the schedules selected by query,
+// loading all their active reservations in one go
+func BuildAgenda(db *gorm.DB, query *gorm.DB) ([]AgendaItem, error) {
+	var schedules []models.Schedule
+	if err := query.Preload("Court").
+		Order("schedules.date ASC, schedules.start_time ASC").
+		Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+
+	agenda := []AgendaItem{}
+	if len(schedules) == 0 {
+		return agenda, nil
+	}
+
+	ids := make([]string, len(schedules))
+	for i, s := range schedules {
+		ids[i] = s.ID
+	}
+
+	var reservations []models.Reservation
+	if err := db.Preload("User", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name") }).
+		Preload("Attendees").
+		Where("schedule_id IN ? AND status IN ?", ids, domain.ActiveReservationStatuses).
+		Order("created_at ASC").
+		Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+
+	bySchedule := map[string][]models.Reservation{}
+	for _, r := range reservations {
+		bySchedule[r.ScheduleID] = append(bySchedule[r.ScheduleID], r)
+	}
+
+	for _, s := range schedules {
+		agenda = append(agenda, agendaItem(s, bySchedule[s.ID]))
+	}
+	return agenda, nil
+}
+
+func agendaItem(s models.Schedule, bookings []models.Reservation) AgendaItem {
+	item := AgendaItem{
+		ScheduleID:  s.ID,
+		Date:        s.Date.Format("2006-01-02"),
+		CourtName:   s.Court.Name,
+		Time:        s.StartTime.String() + " - " + s.EndTime.String(),
+		Status:      "Available",
+		StatusColor: "green",
+		Bookings:    []AgendaBooking{},
+		Attendees:   []AgendaAttendee{},
+		IsAvailable: s.IsAvailable,
+	}
+
+	if !s.IsAvailable {
+		item.Status = "Full / Closed"
+		item.StatusColor = "red"
+	}
+
+	for _, b := range bookings {
+		item.Bookings = append(item.Bookings, AgendaBooking{
+			ReservationID: b.ID,
+			Name:          b.User.Name,
+			Status:        b.Status,
+			Seats:         b.Seats,
+		})
+		item.SeatsBooked += b.Seats
+		if b.CheckedInAt != nil {
+			item.CheckedIn += b.Seats
+		}
+		for _, a := range b.Attendees {
+			item.Attendees = append(item.Attendees, AgendaAttendee{
+				Name:          a.Name,
+				ReservationID: b.ID,
+				WaiverStatus:  a.WaiverStatus,
+				Claimed:       a.UserID != nil,
+			})
+		}
+	}
+
+	switch {
+	case len(bookings) == 1:
+		item.Customer = bookings[0].User.Name
+		if bookings[0].Status == domain.ReservationPending {
+			item.Status = "Pending"
+			item.StatusColor = "yellow"
+		} else {
+			item.Status = "Booked"
+			item.StatusColor = "blue"
+		}
+	case len(bookings) > 1:
+		item.Status = fmt.Sprintf("%d Bookings", len(bookings))
+		item.StatusColor = "blue"
+	case !s.IsAvailable:
+		item.StatusColor = "grey" // Maintenance or just manually closed
+	}
+
+	return item
+}
+
+// AgendaDelta tells the live agenda what happened and carries the updated
+// row of the affected schedule
+type AgendaDelta struct {
+	Type          string     `json:"type"`
+	ReservationID string     `json:"reservation_id,omitempty"`
+	ScheduleID    string     `json:"schedule_id"`
+	Date          string     `json:"date,omitempty"`
+	Item          AgendaItem `json:"item"`
+}
+
+// AgendaStream pushes agenda changes to connected admin dashboards. Like
+// SeatStream it is fed by this process's dispatcher and only works with a
+// single API instance.
+type AgendaStream struct {
+	DB *gorm.DB
+	*Broadcaster[AgendaDelta]
+}
+
+func NewAgendaStream(db *gorm.DB) *AgendaStream {
+	return &AgendaStream{DB: db, Broadcaster: NewBroadcaster[AgendaDelta](agendaHistorySize)}
+}
+
+// AgendaFilter matches deltas for one date
+func AgendaFilter(date string) func(AgendaDelta) bool {
+	return func(d AgendaDelta) bool {
+		return d.Date == date
+	}
+}
+
+// HandleEvent rebuilds the agenda rows touched by an event and broadcasts them
+func (s *AgendaStream) HandleEvent(ctx context.Context, e events.Event) error {
+	deltaType := ""
+	reservationID := ""
+	var scheduleIDs []string
+
+	switch e.Type {
+	case events.ScheduleChanged:
+		var payload events.ScheduleChangedPayload
+		if err := e.Decode(&payload); err != nil {
+			return err
+		}
+		if payload.Action == "deleted" {
+			for _, id := range payload.ScheduleIDs {
+				date := payload.Dates[id]
+				s.Publish(AgendaDelta{
+					Type:       AgendaScheduleRemoved,
+					ScheduleID: id,
+					Date:       date,
+					Item:       AgendaItem{ScheduleID: id, Date: date, Bookings: []AgendaBooking{}, Attendees: []AgendaAttendee{}},
+				})
+			}
+			return nil
+		}
+		deltaType = AgendaScheduleChanged
+		scheduleIDs = payload.ScheduleIDs
+	case events.ReservationRescheduled:
+		var payload events.RescheduledPayload
+		if err := e.Decode(&payload); err != nil {
+			return err
+		}
+		deltaType = AgendaRescheduled
+		reservationID = payload.ReservationID
+		scheduleIDs = []string{payload.FromScheduleID, payload.ScheduleID}
+	default:
+		var payload events.ReservationPayload
+		if err := e.Decode(&payload); err != nil {
+			return err
+		}
+		reservationID = payload.ReservationID
+		scheduleIDs = []string{payload.ScheduleID}
+		switch e.Type {
+		case events.ReservationCreated:
+			deltaType = AgendaBookingCreated
+		case events.ReservationPaid:
+			deltaType = AgendaPaymentReceived
+		case events.ReservationCancelled, events.ReservationRefunded:
+			deltaType = AgendaCancelled
+		case events.ReservationCheckedIn:
+			deltaType = AgendaCheckedIn
+		}
+	}
+
+	if deltaType == "" || len(scheduleIDs) == 0 {
+		return nil
+	}
+
+	items, err := BuildAgenda(s.DB, s.DB.Where("schedules.id IN ?", scheduleIDs))
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		s.Publish(AgendaDelta{
+			Type:          deltaType,
+			ReservationID: reservationID,
+			ScheduleID:    items[i].ScheduleID,
+			Date:          items[i].Date,
+			Item:          items[i],
+		})
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"gorm.io/gorm"
+)
+
+// AuditEntry describes one audit trail record before it is persisted.
+type AuditEntry struct {
+	UserID         string
+	ImpersonatorID string
+	Action         string
+	TableName      string
+	RecordID       string
+	OldData        interface{}
+	NewData        interface{}
+	IPAddress      string
+}
+
+// RecordAudit writes an entry to audit_logs. Failures are logged rather than
+// returned so that auditing never breaks the request that triggered it.
+func RecordAudit(db *gorm.DB, entry AuditEntry) {
+	auditLog := models.AuditLog{
+		UserID:         optionalString(entry.UserID),
+		ImpersonatorID: optionalString(entry.ImpersonatorID),
+		Action:         entry.Action,
+		TableName:      entry.TableName,
+		RecordID:       optionalString(entry.RecordID),
+		IPAddress:      entry.IPAddress,
+	}
+	if entry.OldData != nil {
+		auditLog.OldData, _ = json.Marshal(entry.OldData)
+	}
+	if entry.NewData != nil {
+		auditLog.NewData, _ = json.Marshal(entry.NewData)
+	}
+
+	if err := db.Create(&auditLog).Error; err != nil {
+		log.Printf("Failed to write audit log for %s: %v", entry.Action, err)
+	}
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const broadcasterSubscriberBuffer = 64
+
+// Message is one broadcast value with its stream cursor, "<boot>-<seq>"
+type Message[T any] struct {
+	ID   string
+	Data T
+}
+
+// Subscription receives the messages its filter accepts. Messages is closed
+// when the subscriber falls too far behind; the client then reconnects with
+// its last cursor.
+type Subscription[T any] struct {
+	Messages chan Message[T]
+	match    func(T) bool
+}
+
+// Broadcaster fans messages out to in-process subscribers and keeps a short
+// in-memory history so reconnecting clients can resume where they left off
+type Broadcaster[T any] struct {
+	mu          sync.Mutex
+	bootID      string
+	seq         uint64
+	historySize int
+	history     []Message[T]
+	subs        map[*Subscription[T]]struct{}
+}
+
+func NewBroadcaster[T any](historySize int) *Broadcaster[T] {
+	return &Broadcaster[T]{
+		bootID:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		subs:        map[*Subscription[T]]struct{}{},
+	}
+}
+
+// Subscribe registers a subscriber. When lastEventID is a cursor still in
+// the history, the missed messages are returned with resumed set; otherwise
+// the caller should send a fresh snapshot. cursor is the current position.
+func (b *Broadcaster[T]) Subscribe(match func(T) bool, lastEventID string) (sub *Subscription[T], missed []Message[T], resumed bool, cursor string) {
+	sub = &Subscription[T]{Messages: make(chan Message[T], broadcasterSubscriberBuffer), match: match}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs[sub] = struct{}{}
+	cursor = b.cursor()
+
+	if seq, ok := b.parseCursor(lastEventID); ok {
+		oldest := b.seq - uint64(len(b.history))
+		if seq >= oldest && seq <= b.seq {
+			resumed = true
+			for _, m := range b.history[seq-oldest:] {
+				if sub.match(m.Data) {
+					missed = append(missed, m)
+				}
+			}
+		}
+	}
+
+	return sub, missed, resumed, cursor
+}
+
+// Unsubscribe removes a subscriber
+func (b *Broadcaster[T]) Unsubscribe(sub *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.Messages)
+	}
+}
+
+// Publish assigns the next cursor to data and delivers it
+func (b *Broadcaster[T]) Publish(data T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	m := Message[T]{ID: b.cursor(), Data: data}
+
+	b.history = append(b.history, m)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subs {
+		if !sub.match(data) {
+			continue
+		}
+		select {
+		case sub.Messages <- m:
+		default:
+			// Too slow: drop it, the client resumes from its last cursor
+			delete(b.subs, sub)
+			close(sub.Messages)
+		}
+	}
+}
+
+func (b *Broadcaster[T]) cursor() string {
+	return fmt.Sprintf("%s-%d", b.bootID, b.seq)
+}
+
+// parseCursor accepts only cursors from this process; after a restart the
+// history is gone and clients get a snapshot instead
+func (b *Broadcaster[T]) parseCursor(id string) (uint64, bool) {
+	boot, seqStr, ok := strings.Cut(id, "-")
+	if !ok || boot != b.bootID {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	return seq, err == nil
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	icalProductID = "-//Diro Pilates//Reservations//EN"
+	icalUIDDomain = "diro-pilates"
+)
+
+// icalTimezone returns the VTIMEZONE of the studio. Only fixed-offset zones
+// (such as WIB) are described; for zones with daylight saving ok is false
+// and events are written in UTC instead.
+func icalTimezone() (tzid, vtimezone string, ok bool) {
+	loc := utils.StudioLocation()
+	year := time.Now().Year()
+	name, offset := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+	_, julyOffset := time.Date(year, time.July, 1, 0, 0, 0, 0, loc).Zone()
+	if offset != julyOffset {
+		return "", "", false
+	}
+
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	tzOffset := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	tzid = utils.StudioTimezone()
+
+	vtimezone = "BEGIN:VTIMEZONE\r\n" +
+		"TZID:" + tzid + "\r\n" +
+		"BEGIN:STANDARD\r\n" +
+		"DTSTART:19700101T000000\r\n" +
+		"TZOFFSETFROM:" + tzOffset + "\r\n" +
+		"TZOFFSETTO:" + tzOffset + "\r\n" +
+		"TZNAME:" + name + "\r\n" +
+		"END:STANDARD\r\n" +
+		"END:VTIMEZONE\r\n"
+	return tzid, vtimezone, true
+}
+
+// ICalEvent is one VEVENT
+type ICalEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Status      string // CONFIRMED, TENTATIVE, CANCELLED
+	Modified    time.Time
+}
+
+// ScheduleICalEvent builds the event for a schedule with Court loaded. The
+// UID is stable per schedule so calendar apps update instead of duplicating.
+func ScheduleICalEvent(s *models.Schedule) ICalEvent {
+	status := "CONFIRMED"
+	if !s.IsAvailable {
+		status = "TENTATIVE"
+	}
+
+	return ICalEvent{
+		UID:         fmt.Sprintf("schedule-%s@%s", s.ID, icalUIDDomain),
+		Summary:     "Pilates - " + s.Court.Name,
+		Description: s.Court.Description,
+		Location:    "Diro Pilates - " + s.Court.Name,
+		Start:       domain.ScheduleStart(s),
+		End:         domain.ScheduleEnd(s),
+		Status:      status,
+		Modified:    s.UpdatedAt,
+	}
+}
+
+// ReservationICalEvent builds the event for a reservation with Schedule and
+// Court loaded. Cancelled reservations stay in feeds as CANCELLED so
+// subscribed calendars remove them.
+func ReservationICalEvent(r *models.Reservation) ICalEvent {
+	schedule := r.Schedule
+	schedule.Court = r.Court
+
+	event := ScheduleICalEvent(&schedule)
+
+	event.UID = fmt.Sprintf("reservation-%s@%s", r.ID, icalUIDDomain)
+	event.Modified = r.UpdatedAt
+	event.Description = fmt.Sprintf("Reservation %s (%d seat(s))", r.ID, r.Seats)
+
+	switch r.Status {
+	case domain.ReservationPending:
+		event.Status = "TENTATIVE"
+		event.Description += "\nAwaiting payment"
+	case domain.ReservationCancelled, domain.ReservationRefunded:
+		event.Status = "CANCELLED"
+	default:
+		event.Status = "CONFIRMED"
+	}
+
+	return event
+}
+
+// RenderICal returns an iCalendar (RFC 5545) document
+func RenderICal(name string, events []ICalEvent) string {
+	var b strings.Builder
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:"+icalProductID)
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:"+icalEscape(name))
+	tzid, vtimezone, hasTZ := icalTimezone()
+	if hasTZ {
+		writeICalLine(&b, "X-WR-TIMEZONE:"+tzid)
+		b.WriteString(vtimezone)
+	}
+
+	now := time.Now().UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+e.UID)
+		writeICalLine(&b, "DTSTAMP:"+now)
+		if !e.Modified.IsZero() {
+			writeICalLine(&b, "LAST-MODIFIED:"+e.Modified.UTC().Format("20060102T150405Z"))
+		}
+		if hasTZ {
+			loc := utils.StudioLocation()
+			writeICalLine(&b, "DTSTART;TZID="+tzid+":"+e.Start.In(loc).Format("20060102T150405"))
+			writeICalLine(&b, "DTEND;TZID="+tzid+":"+e.End.In(loc).Format("20060102T150405"))
+		} else {
+			writeICalLine(&b, "DTSTART:"+e.Start.UTC().Format("20060102T150405Z"))
+			writeICalLine(&b, "DTEND:"+e.End.UTC().Format("20060102T150405Z"))
+		}
+		writeICalLine(&b, "SUMMARY:"+icalEscape(e.Summary))
+		if e.Description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+icalEscape(e.Description))
+		}
+		if e.Location != "" {
+			writeICalLine(&b, "LOCATION:"+icalEscape(e.Location))
+		}
+		writeICalLine(&b, "STATUS:"+e.Status)
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icalEscape(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// writeICalLine folds content lines longer than 75 octets without splitting
+// a UTF-8 sequence
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // the leading space counts
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// ICalDay is an all-day entry read from an imported calendar
+type ICalDay struct {
+	UID  string
+	Name string
+	Date time.Time
+}
+
+var icalUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// maxICalEventDays bounds how many days one imported event may cover, so a
+// malformed DTEND cannot produce an endless range
+const maxICalEventDays = 31
+
+// ParseICalDays reads the VEVENTs of an iCalendar file (e.g. a public holiday
+// calendar) as days. Multi-day events yield one entry per day; DTEND is
+// exclusive as in RFC 5545. Timed events count for the studio dates they
+// touch once converted to the studio timezone.
+func ParseICalDays(data string) ([]ICalDay, error) {
+	// Unfold continuation lines first
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	if !strings.Contains(data, "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+
+	var days []ICalDay
+	var inEvent bool
+	var uid, summary string
+	var start, end time.Time
+	var endIsDate bool
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Parameters such as ;VALUE=DATE or ;TZID=... follow the property name
+		prop, params, _ := strings.Cut(name, ";")
+
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent = true
+			uid, summary = "", ""
+			start, end = time.Time{}, time.Time{}
+		case line == "END:VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			first := icalDay(start)
+			last := first.AddDate(0, 0, 1) // exclusive
+			if end.After(start) {
+				if endIsDate {
+					last = icalDay(end)
+				} else {
+					// A timed end covers the day it falls on, unless it is
+					// exactly midnight
+					last = icalDay(end.Add(-time.Nanosecond)).AddDate(0, 0, 1)
+				}
+			}
+			if last.Sub(first) > maxICalEventDays*24*time.Hour {
+				return nil, fmt.Errorf("event %q spans more than %d days", summary, maxICalEventDays)
+			}
+			for d := first; d.Before(last); d = d.AddDate(0, 0, 1) {
+				days = append(days, ICalDay{UID: uid, Name: summary, Date: d})
+			}
+		case !inEvent:
+			continue
+		case prop == "UID":
+			uid = value
+		case prop == "SUMMARY":
+			summary = icalUnescaper.Replace(value)
+		case prop == "DTSTART":
+			start, _ = parseICalTime(params, value)
+		case prop == "DTEND":
+			end, endIsDate = parseICalTime(params, value)
+		}
+	}
+
+	return days, nil
+}
+
+// parseICalTime reads a DATE or DATE-TIME value. DATE values come back as
+// midnight UTC with isDate set. DATE-TIME values in UTC or with a TZID are
+// converted to the studio timezone; floating times are taken as studio time.
+func parseICalTime(params, value string) (t time.Time, isDate bool) {
+	if len(value) == 8 {
+		d, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return d, true
+	}
+
+	studio := utils.StudioLocation()
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t.In(studio), false
+	}
+
+	loc := studio
+	for _, param := range strings.Split(params, ";") {
+		if tzid, ok := strings.CutPrefix(param, "TZID="); ok {
+			if l, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(studio), false
+}
+
+// icalDay is the calendar date of t as midnight UTC, the form holidays are stored in
+func icalDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// InboxEvents are the event types that create in-app notifications
+var InboxEvents = []string{events.ReservationPaid, events.ReservationCancelled, events.ReservationRefunded}
+
+// Inbox writes in-app notifications that the frontend shows under the bell
+type Inbox struct {
+	DB       *gorm.DB
+	Notifier *Notifier
+}
+
+func NewInbox(db *gorm.DB, notifier *Notifier) *Inbox {
+	return &Inbox{DB: db, Notifier: notifier}
+}
+
+// Post renders template in the user's language and adds it to their inbox.
+// A non-empty dedupKey makes repeated calls for the same occasion add one entry.
+func (i *Inbox) Post(userID, notificationType, template string, data map[string]interface{}, dedupKey string) error {
+	var user models.User
+	if err := i.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	if user.AnonymizedAt != nil {
+		return nil
+	}
+
+	title, body, err := RenderNotification(template, user.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		UserID: userID,
+		Type:   notificationType,
+		Title:  title,
+		Body:   body,
+		Data:   raw,
+	}
+	if dedupKey != "" {
+		notification.DedupKey = &dedupKey
+	}
+
+	return i.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&notification).Error
+}
+
+// HandleEvent adds payment confirmations and studio cancellations to the
+// booker's inbox. A studio cancellation of a paid booking ends in a refund
+// (e.g. a blackout), so admin refunds count as cancellations too.
+func (i *Inbox) HandleEvent(ctx context.Context, e events.Event) error {
+	var payload events.ReservationPayload
+	if err := e.Decode(&payload); err != nil {
+		return err
+	}
+
+	var notificationType, template string
+	switch {
+	case e.Type == events.ReservationPaid:
+		notificationType, template = models.NotificationPaymentConfirmed, TemplateInboxPaymentConfirmed
+	case (e.Type == events.ReservationCancelled || e.Type == events.ReservationRefunded) && payload.Source == domain.SourceAdmin:
+		notificationType, template = models.NotificationClassCancelled, TemplateInboxClassCancelled
+	default:
+		return nil
+	}
+
+	data, err := i.Notifier.ReservationData(payload.ReservationID)
+	if err != nil {
+		return err
+	}
+
+	return i.Post(payload.UserID, notificationType, template, data, e.ID)
+}
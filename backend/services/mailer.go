@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends plain text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends through the server configured by SMTP_HOST, SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+type SMTPMailer struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+// LogMailer prints messages instead of sending them; used when SMTP is not configured
+type LogMailer struct{}
+
+// FileMailer appends messages to a file, for inspecting mail in development
+type FileMailer struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewMailerFromEnv returns an SMTPMailer when SMTP_HOST is set, a FileMailer
+// when MAIL_SINK_FILE is set, otherwise a LogMailer
+func NewMailerFromEnv() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		if path := os.Getenv("MAIL_SINK_FILE"); path != "" {
+			return &FileMailer{Path: path}
+		}
+		return LogMailer{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@diropilates.com"
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return &SMTPMailer{Addr: host + ":" + port, Auth: auth, From: from}
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", to, err)
+	}
+	return nil
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "=== %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+	return err
+}
+
+func (LogMailer) Send(to, subject, body string) error {
+	log.Printf("[mail] to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// MessagingProvider sends a text message to a phone number in E.164 format
+type MessagingProvider interface {
+	SendMessage(ctx context.Context, phone, text string) error
+}
+
+// HTTPMessagingProvider posts messages to a WhatsApp Business (Cloud API
+// compatible) endpoint configured by WHATSAPP_API_URL and WHATSAPP_API_TOKEN
+type HTTPMessagingProvider struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// FakeMessagingProvider keeps sent messages in memory and logs them; used in
+// development and whenever no provider is configured
+type FakeMessagingProvider struct {
+	mu       sync.Mutex
+	Messages []FakeMessage
+}
+
+type FakeMessage struct {
+	Phone  string
+	Text   string
+	SentAt time.Time
+}
+
+// NewMessagingProviderFromEnv returns the HTTP provider when WHATSAPP_API_URL is set, otherwise the fake
+func NewMessagingProviderFromEnv() MessagingProvider {
+	url := os.Getenv("WHATSAPP_API_URL")
+	if url == "" {
+		return &FakeMessagingProvider{}
+	}
+	return &HTTPMessagingProvider{
+		URL:    url,
+		Token:  os.Getenv("WHATSAPP_API_TOKEN"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *HTTPMessagingProvider) SendMessage(ctx context.Context, phone, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                phone,
+		"type":              "text",
+		"text":              map[string]string{"body": text},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("messaging provider returned %d: %s", resp.StatusCode, snippet)
+	}
+	return nil
+}
+
+func (p *FakeMessagingProvider) SendMessage(ctx context.Context, phone, text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Messages = append(p.Messages, FakeMessage{Phone: phone, Text: text, SentAt: time.Now()})
+	log.Printf("[whatsapp] to=%s\n%s", phone, text)
+	return nil
+}
+
+// WhatsAppChannel delivers notifications to the user's verified phone.
+// Users opt in through their notification preferences.
+type WhatsAppChannel struct {
+	Provider MessagingProvider
+}
+
+func (WhatsAppChannel) Name() string         { return models.ChannelWhatsApp }
+func (WhatsAppChannel) DefaultEnabled() bool { return false }
+
+func (WhatsAppChannel) Address(user *models.User) string {
+	if user.AnonymizedAt != nil || user.Phone == nil || user.PhoneVerifiedAt == nil {
+		return ""
+	}
+	return *user.Phone
+}
+
+func (ch WhatsAppChannel) Send(ctx context.Context, to, subject, body string) error {
+	return ch.Provider.SendMessage(ctx, to, "*"+subject+"*\n\n"+body)
+}
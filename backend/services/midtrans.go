@@ -1,8 +1,11 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/Giriathallah/diro-pilates-backend/models"
 	"github.com/midtrans/midtrans-go"
@@ -35,6 +38,12 @@ func NewMidtransService() *MidtransService {
 }
 
 func (s *MidtransService) GenerateSnapToken(reservation *models.Reservation, user *models.User) (string, string, error) {
+	return s.GenerateSnapTokenWithExpiry(reservation, user, 15*time.Minute)
+}
+
+// GenerateSnapTokenWithExpiry is GenerateSnapToken with a custom payment window,
+// e.g. for approved private sessions the customer pays later
+func (s *MidtransService) GenerateSnapTokenWithExpiry(reservation *models.Reservation, user *models.User, expiry time.Duration) (string, string, error) {
 	// Convert float amount to int64 required by Midtrans
 	amount := int64(reservation.TotalAmount)
 
@@ -46,7 +55,7 @@ func (s *MidtransService) GenerateSnapToken(reservation *models.Reservation, use
 		},
 		Expiry: &snap.ExpiryDetails{
 			Unit:     "minute",
-			Duration: 15,
+			Duration: int64(expiry / time.Minute),
 		},
 		CreditCard: &snap.CreditCardDetails{
 			Secure: true,
@@ -74,6 +83,148 @@ func (s *MidtransService) GenerateSnapToken(reservation *models.Reservation, use
 	return snapResp.Token, snapResp.RedirectURL, nil
 }
 
+// CartLine is one line item of a cart checkout
+type CartLine struct {
+	ScheduleID string
+	Name       string
+	Price      float64 // per seat
+	Seats      int
+}
+
+// GenerateCartSnapToken creates one Snap transaction for all lines of a cart booking
+func (s *MidtransService) GenerateCartSnapToken(orderID string, lines []CartLine, user *models.User) (string, string, error) {
+	var gross int64
+	items := make([]midtrans.ItemDetails, 0, len(lines))
+	for _, line := range lines {
+		name := line.Name
+		if runes := []rune(name); len(runes) > 50 {
+			// Midtrans rejects item names longer than 50 characters; cut by
+			// rune so a multi-byte character is never split
+			name = string(runes[:50])
+		}
+		items = append(items, midtrans.ItemDetails{
+			ID:    line.ScheduleID,
+			Name:  name,
+			Price: int64(line.Price),
+			Qty:   int32(line.Seats),
+		})
+		gross += int64(line.Price) * int64(line.Seats)
+	}
+
+	req := &snap.Request{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  orderID,
+			GrossAmt: gross,
+		},
+		Expiry: &snap.ExpiryDetails{
+			Unit:     "minute",
+			Duration: 15,
+		},
+		CreditCard: &snap.CreditCardDetails{
+			Secure: true,
+		},
+		CustomerDetail: &midtrans.CustomerDetails{
+			FName: user.Name,
+			Email: user.Email,
+		},
+		Items: &items,
+	}
+
+	snapResp, err := s.Client.CreateTransaction(req)
+	if err != nil {
+		return "", "", err
+	}
+
+	return snapResp.Token, snapResp.RedirectURL, nil
+}
+
+// PaymentStatusFromMidtrans maps a Midtrans transaction/fraud status to our payment status.
+// An empty result means the status carries no actionable change.
+func PaymentStatusFromMidtrans(transactionStatus, fraudStatus string) string {
+	switch transactionStatus {
+	case "capture":
+		if fraudStatus == "challenge" {
+			return "pending"
+		} else if fraudStatus == "accept" {
+			return "success"
+		}
+	case "settlement":
+		return "success"
+	case "deny", "expire", "cancel":
+		return "failed"
+	case "pending":
+		return "pending"
+	case "refund":
+		return "refunded"
+	case "partial_refund":
+		// The order stays settled; see domain.PaymentPartiallyRefunded
+		return "partial_refund"
+	}
+	return ""
+}
+
+// GenerateAdjustmentSnapToken charges an extra amount for an existing reservation
+// (e.g. a reschedule to a more expensive slot) under its own order ID
+func (s *MidtransService) GenerateAdjustmentSnapToken(orderID string, amount float64, itemName string, reservation *models.Reservation, user *models.User) (string, string, error) {
+	req := &snap.Request{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  orderID,
+			GrossAmt: int64(amount),
+		},
+		Expiry: &snap.ExpiryDetails{
+			Unit:     "minute",
+			Duration: 15,
+		},
+		CreditCard: &snap.CreditCardDetails{
+			Secure: true,
+		},
+		CustomerDetail: &midtrans.CustomerDetails{
+			FName: user.Name,
+			Email: user.Email,
+		},
+		Items: &[]midtrans.ItemDetails{
+			{
+				ID:    reservation.ScheduleID,
+				Name:  itemName,
+				Price: int64(amount),
+				Qty:   1,
+			},
+		},
+	}
+
+	snapResp, err := s.Client.CreateTransaction(req)
+	if err != nil {
+		return "", "", err
+	}
+
+	return snapResp.Token, snapResp.RedirectURL, nil
+}
+
+// RefundTransaction refunds part or all of a settled Midtrans order
+func (s *MidtransService) RefundTransaction(orderID string, amount float64, refundKey string, reason string) error {
+	_, err := s.Core.RefundTransaction(orderID, &coreapi.RefundReq{
+		RefundKey: refundKey,
+		Amount:    int64(amount),
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsTransactionNotFound reports whether err is Midtrans saying the order has no
+// transaction, e.g. a Snap page that was never opened
+func IsTransactionNotFound(err error) bool {
+	var midtransErr *midtrans.Error
+	return errors.As(err, &midtransErr) && midtransErr.StatusCode == http.StatusNotFound
+}
+
 func (s *MidtransService) VerifyTransaction(orderID string) (*coreapi.TransactionStatusResponse, error) {
-	return s.Core.CheckTransaction(orderID)
+	resp, err := s.Core.CheckTransaction(orderID)
+	if err != nil {
+		// Returned separately: a nil *midtrans.Error is not a nil error
+		return nil, err
+	}
+	return resp, nil
 }
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Notification templates
+const (
+	TemplateReservationCreated   = "reservation_created"
+	TemplatePaymentReceived      = "payment_received"
+	TemplateReservationCancelled = "reservation_cancelled"
+	TemplateClassReminder        = "class_reminder"
+
+	// In-app inbox entries; the subject is the title
+	TemplateInboxPaymentConfirmed = "inbox_payment_confirmed"
+	TemplateInboxClassCancelled   = "inbox_class_cancelled"
+	TemplateInboxWaitlistOffer    = "inbox_waitlist_offer"
+)
+
+const defaultLocale = "id"
+
+type messageTemplate struct {
+	Subject string
+	Body    string
+}
+
+// notificationTemplates holds the text of every message per locale.
+// Data keys: Name, Court, Date, StartTime, EndTime, Amount, ReservationID, Seats;
+// reminders also get Hours and Minutes before the session.
+var notificationTemplates = map[string]map[string]messageTemplate{
+	"id": {
+		TemplateReservationCreated: {
+			Subject: "Reservasi diterima - {{.Court}} {{.Date}}",
+			Body: `Halo {{.Name}},
+
+Reservasi Anda sudah kami terima:
+{{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}}{{if gt .Seats 1}} ({{.Seats}} kursi){{end}}
+Total: {{.Amount}}
+
+Silakan selesaikan pembayaran agar kursi Anda tidak dilepas.
+
+Kode reservasi: {{.ReservationID}}`,
+		},
+		TemplatePaymentReceived: {
+			Subject: "Pembayaran berhasil - {{.Court}} {{.Date}}",
+			Body: `Halo {{.Name}},
+
+Pembayaran sebesar {{.Amount}} sudah kami terima. Sampai jumpa di kelas:
+{{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}}
+
+Kode reservasi: {{.ReservationID}}`,
+		},
+		TemplateReservationCancelled: {
+			Subject: "Reservasi dibatalkan - {{.Court}} {{.Date}}",
+			Body: `Halo {{.Name}},
+
+Reservasi Anda untuk {{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}} telah dibatalkan.
+
+Kode reservasi: {{.ReservationID}}`,
+		},
+		TemplateClassReminder: {
+			Subject: "Pengingat kelas - {{.Court}} {{.Date}} {{.StartTime}}",
+			Body: `Halo {{.Name}},
+
+Kelas Anda dimulai {{if ge .Hours 1}}{{.Hours}} jam{{else}}{{.Minutes}} menit{{end}} lagi:
+{{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}}
+
+Mohon datang 10 menit lebih awal. Sampai jumpa!`,
+		},
+		TemplateInboxPaymentConfirmed: {
+			Subject: "Pembayaran dikonfirmasi",
+			Body:    "Pembayaran {{.Amount}} untuk {{.Court}}, {{.Date}} {{.StartTime}} sudah kami terima.",
+		},
+		TemplateInboxClassCancelled: {
+			Subject: "Kelas dibatalkan oleh studio",
+			Body:    "Mohon maaf, kelas {{.Court}}, {{.Date}} {{.StartTime}} dibatalkan oleh studio.",
+		},
+		TemplateInboxWaitlistOffer: {
+			Subject: "Kursi tersedia",
+			Body:    "Ada kursi kosong di {{.Court}}, {{.Date}} {{.StartTime}}. Segera booking sebelum diambil orang lain.",
+		},
+	},
+	"en": {
+		TemplateReservationCreated: {
+			Subject: "Booking received - {{.Court}} {{.Date}}",
+			Body: `Hi {{.Name}},
+
+We have received your booking:
+{{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}}{{if gt .Seats 1}} ({{.Seats}} seats){{end}}
+Total: {{.Amount}}
+
+Please complete the payment so your seat is not released.
+
+Booking code: {{.ReservationID}}`,
+		},
+		TemplatePaymentReceived: {
+			Subject: "Payment received - {{.Court}} {{.Date}}",
+			Body: `Hi {{.Name}},
+
+We have received your payment of {{.Amount}}. See you in class:
+{{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}}
+
+Booking code: {{.ReservationID}}`,
+		},
+		TemplateReservationCancelled: {
+			Subject: "Booking cancelled - {{.Court}} {{.Date}}",
+			Body: `Hi {{.Name}},
+
+Your booking for {{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}} has been cancelled.
+
+Booking code: {{.ReservationID}}`,
+		},
+		TemplateClassReminder: {
+			Subject: "Class reminder - {{.Court}} {{.Date}} {{.StartTime}}",
+			Body: `Hi {{.Name}},
+
+Your class starts in {{if ge .Hours 1}}{{.Hours}} hour(s){{else}}{{.Minutes}} minutes{{end}}:
+{{.Court}}, {{.Date}} {{.StartTime}} - {{.EndTime}}
+
+Please arrive 10 minutes early. See you soon!`,
+		},
+		TemplateInboxPaymentConfirmed: {
+			Subject: "Payment confirmed",
+			Body:    "We have received {{.Amount}} for {{.Court}}, {{.Date}} {{.StartTime}}.",
+		},
+		TemplateInboxClassCancelled: {
+			Subject: "Class cancelled by the studio",
+			Body:    "Sorry, {{.Court}}, {{.Date}} {{.StartTime}} has been cancelled by the studio.",
+		},
+		TemplateInboxWaitlistOffer: {
+			Subject: "A seat opened up",
+			Body:    "A seat is free in {{.Court}}, {{.Date}} {{.StartTime}}. Book it before someone else does.",
+		},
+	},
+}
+
+// RenderNotification renders a template in locale, falling back to the default locale
+func RenderNotification(name, locale string, data map[string]interface{}) (subject string, body string, err error) {
+	tmpl, ok := notificationTemplates[locale][name]
+	if !ok {
+		locale = defaultLocale
+		tmpl, ok = notificationTemplates[locale][name]
+	}
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification template %q", name)
+	}
+
+	if subject, err = renderText(name+".subject", tmpl.Subject, data); err != nil {
+		return "", "", err
+	}
+	if body, err = renderText(name+".body", tmpl.Body, data); err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+func renderText(name, text string, data map[string]interface{}) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// FormatRupiah formats an amount as "Rp 150.000"
+func FormatRupiah(amount float64) string {
+	digits := fmt.Sprintf("%d", int64(amount))
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	groups = append([]string{digits}, groups...)
+	return "Rp " + strings.Join(groups, ".")
+}
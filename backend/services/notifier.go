@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+// NotificationChannel delivers rendered messages to one kind of address
+type NotificationChannel interface {
+	Name() string
+	// DefaultEnabled applies when the user has no preference for the channel
+	DefaultEnabled() bool
+	// Address returns where to reach user, or "" when the channel cannot
+	Address(user *models.User) string
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// EmailChannel sends notifications through a Mailer
+type EmailChannel struct {
+	Mailer Mailer
+}
+
+func (EmailChannel) Name() string         { return models.ChannelEmail }
+func (EmailChannel) DefaultEnabled() bool { return true }
+
+func (EmailChannel) Address(user *models.User) string {
+	if user.AnonymizedAt != nil {
+		return ""
+	}
+	return user.Email
+}
+
+func (ch EmailChannel) Send(ctx context.Context, to, subject, body string) error {
+	return ch.Mailer.Send(to, subject, body)
+}
+
+// Notifier renders templates in the user's language and sends them over every
+// channel the user has enabled, logging each delivery
+type Notifier struct {
+	DB       *gorm.DB
+	channels []NotificationChannel
+}
+
+func NewNotifier(db *gorm.DB, channels ...NotificationChannel) *Notifier {
+	return &Notifier{DB: db, channels: channels}
+}
+
+// Channels returns the configured channels
+func (n *Notifier) Channels() []NotificationChannel {
+	return n.channels
+}
+
+// Channel returns the configured channel with name, or nil
+func (n *Notifier) Channel(name string) NotificationChannel {
+	for _, ch := range n.channels {
+		if ch.Name() == name {
+			return ch
+		}
+	}
+	return nil
+}
+
+// Notify sends template to the user over each enabled channel. dedupKey
+// identifies the occasion (e.g. the outbox event ID): a channel that already
+// delivered for it is not sent again, so callers may retry freely. The
+// returned error lists the channels that failed.
+func (n *Notifier) Notify(ctx context.Context, userID, template string, data map[string]interface{}, dedupKey string) error {
+	var user models.User
+	if err := n.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	enabled, err := n.EnabledChannels(userID)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if _, ok := data["Name"]; !ok {
+		data["Name"] = user.Name
+	}
+
+	var failures []string
+	for _, ch := range n.channels {
+		if err := n.deliver(ctx, ch, &user, enabled[ch.Name()], template, data, dedupKey+":"+ch.Name()); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", ch.Name(), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// EnabledChannels returns the on/off state of every channel for the user
+func (n *Notifier) EnabledChannels(userID string) (map[string]bool, error) {
+	var prefs []models.NotificationPreference
+	if err := n.DB.Where("user_id = ?", userID).Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+
+	enabled := map[string]bool{}
+	for _, ch := range n.channels {
+		enabled[ch.Name()] = ch.DefaultEnabled()
+	}
+	for _, p := range prefs {
+		if _, ok := enabled[p.Channel]; ok {
+			enabled[p.Channel] = p.Enabled
+		}
+	}
+	return enabled, nil
+}
+
+func (n *Notifier) deliver(ctx context.Context, ch NotificationChannel, user *models.User, enabled bool, template string, data map[string]interface{}, key string) error {
+	var delivery models.NotificationDelivery
+	err := n.DB.Where("dedup_key = ?", key).First(&delivery).Error
+	if err == nil && delivery.Status != models.NotificationFailed {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	subject, body, err := RenderNotification(template, user.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	delivery.UserID = user.ID
+	delivery.DedupKey = key
+	delivery.Template = template
+	delivery.Channel = ch.Name()
+	delivery.Locale = user.Locale
+	delivery.Subject = subject
+	delivery.Body = body
+	delivery.Recipient = ch.Address(user)
+
+	var sendErr error
+	switch {
+	case !enabled || delivery.Recipient == "":
+		delivery.Status = models.NotificationSkipped
+	default:
+		delivery.Attempts++
+		if sendErr = ch.Send(ctx, delivery.Recipient, subject, body); sendErr != nil {
+			delivery.Status = models.NotificationFailed
+			delivery.LastError = sendErr.Error()
+		} else {
+			now := time.Now()
+			delivery.Status = models.NotificationSent
+			delivery.SentAt = &now
+			delivery.LastError = ""
+		}
+	}
+
+	if err := n.DB.Save(&delivery).Error; err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// notificationTemplateForEvent maps domain events to the message sent to the booker
+var notificationTemplateForEvent = map[string]string{
+	events.ReservationCreated:   TemplateReservationCreated,
+	events.ReservationPaid:      TemplatePaymentReceived,
+	events.ReservationCancelled: TemplateReservationCancelled,
+	events.ReservationRefunded:  TemplateReservationCancelled, // refunded bookings are over too
+}
+
+// NotificationEvents are the event types HandleEvent reacts to
+var NotificationEvents = []string{events.ReservationCreated, events.ReservationPaid, events.ReservationCancelled, events.ReservationRefunded}
+
+// HandleEvent is the dispatcher subscriber that turns reservation events into notifications
+func (n *Notifier) HandleEvent(ctx context.Context, e events.Event) error {
+	template, ok := notificationTemplateForEvent[e.Type]
+	if !ok {
+		return nil
+	}
+
+	var payload events.ReservationPayload
+	if err := e.Decode(&payload); err != nil {
+		return err
+	}
+
+	data, err := n.ReservationData(payload.ReservationID)
+	if err != nil {
+		return err
+	}
+
+	return n.Notify(ctx, payload.UserID, template, data, e.ID)
+}
+
+// ReservationData is the template data describing a reservation
+func (n *Notifier) ReservationData(reservationID string) (map[string]interface{}, error) {
+	var reservation models.Reservation
+	if err := n.DB.Preload("Court").Preload("Schedule").First(&reservation, "id = ?", reservationID).Error; err != nil {
+		return nil, err
+	}
+
+	seats := reservation.Seats
+	if seats < 1 {
+		seats = 1
+	}
+
+	return map[string]interface{}{
+		"ReservationID": reservation.ID,
+		"Court":         reservation.Court.Name,
+		"Date":          reservation.Schedule.Date.Format("02-01-2006"),
+		"StartTime":     reservation.Schedule.StartTime.String(),
+		"EndTime":       reservation.Schedule.EndTime.String(),
+		"Amount":        FormatRupiah(reservation.TotalAmount),
+		"Seats":         seats,
+	}, nil
+}
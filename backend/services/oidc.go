@@ -0,0 +1,322 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownOIDCProvider = errors.New("unknown OIDC provider")
+
+// OIDCProvider is configured by issuer URL; endpoints are read from the
+// issuer's discovery document, so a local mock server works the same as Google.
+type OIDCProvider struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is the verified subset of ID token claims we rely on
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+type OIDCService struct {
+	Providers  map[string]*OIDCProvider
+	HTTPClient *http.Client
+}
+
+// NewOIDCService reads providers from the environment:
+//
+//	OIDC_PROVIDERS=google,mock
+//	OIDC_GOOGLE_ISSUER=https://accounts.google.com
+//	OIDC_GOOGLE_CLIENT_ID=... OIDC_GOOGLE_CLIENT_SECRET=... OIDC_GOOGLE_REDIRECT_URL=...
+func NewOIDCService() *OIDCService {
+	s := &OIDCService{
+		Providers:  map[string]*OIDCProvider{},
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		scopes := []string{"openid", "email", "profile"}
+		if v := os.Getenv(prefix + "SCOPES"); v != "" {
+			scopes = strings.Fields(v)
+		}
+
+		s.Providers[name] = &OIDCProvider{
+			Name:         name,
+			IssuerURL:    strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       scopes,
+		}
+	}
+
+	return s
+}
+
+func (s *OIDCService) Provider(name string) (*OIDCProvider, error) {
+	p, ok := s.Providers[name]
+	if !ok || p.IssuerURL == "" || p.ClientID == "" {
+		return nil, ErrUnknownOIDCProvider
+	}
+	return p, nil
+}
+
+// NewPKCE returns a code verifier and its S256 challenge
+func NewPKCE() (verifier string, challenge string, err error) {
+	verifier, err = RandomURLSafe(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomURLSafe returns n random bytes encoded for use in URLs
+func RandomURLSafe(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthorizationURL builds the authorization code + PKCE redirect
+func (s *OIDCService) AuthorizationURL(p *OIDCProvider, state, nonce, codeChallenge string) (string, error) {
+	d, err := s.discover(p)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and verifies the ID token
+func (s *OIDCService) Exchange(p *OIDCProvider, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	d, err := s.discover(p)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	resp, err := s.HTTPClient.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return s.verifyIDToken(p, d, tokenResp.IDToken, nonce)
+}
+
+func (s *OIDCService) verifyIDToken(p *OIDCProvider, d *oidcDiscovery, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	token, err := jwt.Parse(rawIDToken, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return s.publicKey(p, d, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid id_token claims")
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Picture, _ = claims["picture"].(string)
+
+	// Some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+
+	return identity, nil
+}
+
+func (s *OIDCService) discover(p *OIDCProvider) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	resp, err := s.HTTPClient.Get(p.IssuerURL + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned %d", resp.StatusCode)
+	}
+
+	var d oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	if d.Issuer != p.IssuerURL {
+		return nil, fmt.Errorf("issuer mismatch: configured %s, discovered %s", p.IssuerURL, d.Issuer)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// publicKey returns the signing key for kid, refreshing the JWKS once when
+// the key is unknown (providers rotate keys)
+func (s *OIDCService) publicKey(p *OIDCProvider, d *oidcDiscovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	keys, err := s.fetchJWKS(d.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+func (s *OIDCService) fetchJWKS(jwksURI string) (map[string]*rsa.PublicKey, error) {
+	resp, err := s.HTTPClient.Get(jwksURI)
+	if err != nil {
+		return nil, fmt.Errorf("jwks request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+	"github.com/Giriathallah/diro-pilates-backend/utils"
+)
+
+const (
+	reminderPollInterval = time.Minute
+	reminderBatchSize    = 50
+	reminderMaxAttempts  = 5
+	reminderRetryDelay   = time.Minute // doubled after every failed attempt
+	// reminderClaimLease keeps a claimed batch from other instances while it is sent
+	reminderClaimLease = 10 * time.Minute
+)
+
+var defaultReminderOffsets = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+// ReminderEvents are the event types the reminder scheduler reacts to
+var ReminderEvents = []string{events.ReservationPaid, events.ReservationCancelled, events.ReservationRefunded, events.ReservationRescheduled, events.ScheduleChanged}
+
+// ReminderScheduler queues class reminders for paid and confirmed
+// reservations and sends them when they fall due
+type ReminderScheduler struct {
+	DB       *gorm.DB
+	Notifier *Notifier
+	Offsets  []time.Duration
+}
+
+// NewReminderScheduler reads offsets from REMINDER_OFFSETS, e.g. "24h,2h"
+func NewReminderScheduler(db *gorm.DB, notifier *Notifier) *ReminderScheduler {
+	offsets := parseReminderOffsets(os.Getenv("REMINDER_OFFSETS"))
+	if len(offsets) == 0 {
+		offsets = defaultReminderOffsets
+	}
+	return &ReminderScheduler{DB: db, Notifier: notifier, Offsets: offsets}
+}
+
+func parseReminderOffsets(value string) []time.Duration {
+	var offsets []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d <= 0 {
+			log.Printf("Ignoring invalid reminder offset %q", part)
+			continue
+		}
+		offsets = append(offsets, d)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	return offsets
+}
+
+// Queue creates the reminders of a reservation for its current schedule.
+// Offsets already in the past are skipped; reminders cancelled earlier (e.g.
+// the reservation moved away and back) are queued again.
+func (rs *ReminderScheduler) Queue(db *gorm.DB, r *models.Reservation) error {
+	var schedule models.Schedule
+	if err := db.First(&schedule, "id = ?", r.ScheduleID).Error; err != nil {
+		return err
+	}
+
+	start := domain.ScheduleStart(&schedule)
+
+	now := time.Now()
+	for _, offset := range rs.Offsets {
+		sendAt := start.Add(-offset)
+		if !sendAt.After(now) {
+			continue
+		}
+
+		reminder := models.ClassReminder{
+			ReservationID: r.ID,
+			ScheduleID:    schedule.ID,
+			OffsetMinutes: int(offset / time.Minute),
+			UserID:        r.UserID,
+			SendAt:        sendAt,
+			Status:        models.ReminderQueued,
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "reservation_id"}, {Name: "schedule_id"}, {Name: "offset_minutes"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"status":  models.ReminderQueued,
+				"send_at": sendAt,
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: clause.Column{Table: "class_reminders", Name: "status"}, Value: models.ReminderCancelled},
+			}},
+		}).Create(&reminder).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cancel drops queued reminders of a reservation, except those for keepScheduleID
+func (rs *ReminderScheduler) Cancel(db *gorm.DB, reservationID, keepScheduleID string) error {
+	query := db.Model(&models.ClassReminder{}).
+		Where("reservation_id = ? AND status = ?", reservationID, models.ReminderQueued)
+	if keepScheduleID != "" {
+		query = query.Where("schedule_id <> ?", keepScheduleID)
+	}
+	return query.Update("status", models.ReminderCancelled).Error
+}
+
+// HandleEvent keeps the queue in step with reservation and schedule changes
+func (rs *ReminderScheduler) HandleEvent(ctx context.Context, e events.Event) error {
+	if e.Type == events.ScheduleChanged {
+		return rs.handleScheduleChanged(e)
+	}
+
+	var payload events.ReservationPayload
+	if err := e.Decode(&payload); err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case events.ReservationCancelled, events.ReservationRefunded:
+		return rs.Cancel(rs.DB, payload.ReservationID, "")
+	case events.ReservationPaid, events.ReservationRescheduled:
+		var reservation models.Reservation
+		if err := rs.DB.First(&reservation, "id = ?", payload.ReservationID).Error; err != nil {
+			return err
+		}
+		if err := rs.Cancel(rs.DB, reservation.ID, reservation.ScheduleID); err != nil {
+			return err
+		}
+		if !remindable(reservation.Status) {
+			return nil
+		}
+		return rs.Queue(rs.DB, &reservation)
+	}
+	return nil
+}
+
+// handleScheduleChanged moves the queued reminders of schedules whose times
+// were shifted
+func (rs *ReminderScheduler) handleScheduleChanged(e events.Event) error {
+	var payload events.ScheduleChangedPayload
+	if err := e.Decode(&payload); err != nil {
+		return err
+	}
+	if payload.Action != "shifted" || len(payload.ScheduleIDs) == 0 {
+		return nil
+	}
+
+	var reservations []models.Reservation
+	if err := rs.DB.Where("schedule_id IN ? AND status IN ?", payload.ScheduleIDs,
+		[]string{domain.ReservationPaid, domain.ReservationConfirmed}).
+		Find(&reservations).Error; err != nil {
+		return err
+	}
+
+	for i := range reservations {
+		// Cancelled rows are queued again by Queue with the new send time
+		if err := rs.Cancel(rs.DB, reservations[i].ID, ""); err != nil {
+			return err
+		}
+		if err := rs.Queue(rs.DB, &reservations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func remindable(status string) bool {
+	return status == domain.ReservationPaid || status == domain.ReservationConfirmed
+}
+
+// Start runs the reminder loop until ctx is cancelled
+func (rs *ReminderScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(reminderPollInterval)
+		defer ticker.Stop()
+
+		for {
+			rs.queueMissing()
+			for {
+				// Keep draining while batches are full and reminders leave
+				// the queue; failed ones wait for their retry time
+				claimed, settled := rs.sendDue(ctx)
+				if claimed < reminderBatchSize || settled == 0 {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// queueMissing catches reservations that became paid or confirmed without an
+// event (e.g. confirmed by an admin) or before reminders existed
+func (rs *ReminderScheduler) queueMissing() {
+	if len(rs.Offsets) == 0 {
+		return
+	}
+	now := utils.StudioNow()
+	horizon := now.Add(rs.Offsets[0] + 24*time.Hour)
+
+	var reservations []models.Reservation
+	if err := rs.DB.
+		Joins("JOIN schedules ON schedules.id = reservations.schedule_id").
+		Where("reservations.status IN ?", []string{domain.ReservationPaid, domain.ReservationConfirmed}).
+		Where("schedules.date BETWEEN ? AND ?", now.AddDate(0, 0, -1).Format("2006-01-02"), horizon.Format("2006-01-02")).
+		Where("NOT EXISTS (SELECT 1 FROM class_reminders cr WHERE cr.reservation_id = reservations.id AND cr.schedule_id = reservations.schedule_id)").
+		Find(&reservations).Error; err != nil {
+		log.Printf("Reminder sweep failed: %v", err)
+		return
+	}
+
+	for i := range reservations {
+		if err := rs.Queue(rs.DB, &reservations[i]); err != nil {
+			log.Printf("Queueing reminders for %s failed: %v", reservations[i].ID, err)
+		}
+	}
+}
+
+// sendDue sends one batch of due reminders and returns how many it claimed
+// and how many of those left the queue (sent, cancelled or given up)
+func (rs *ReminderScheduler) sendDue(ctx context.Context) (claimed, settled int) {
+	reminders, err := rs.claim()
+	if err != nil {
+		log.Printf("Reminder batch failed: %v", err)
+		return 0, 0
+	}
+
+	for i := range reminders {
+		if rs.send(ctx, &reminders[i]) {
+			settled++
+		}
+	}
+	return len(reminders), settled
+}
+
+// claim picks due reminders and leases them by pushing send_at out by
+// reminderClaimLease in a short transaction, like the outbox dispatcher, so
+// messages are sent without holding row locks. A reminder whose instance dies
+// mid-batch is sent after the lease; the delivery log keeps it from going out twice.
+func (rs *ReminderScheduler) claim() ([]models.ClassReminder, error) {
+	var reminders []models.ClassReminder
+
+	err := rs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND send_at <= ?", models.ReminderQueued, time.Now()).
+			Order("send_at ASC").
+			Limit(reminderBatchSize).
+			Find(&reminders).Error; err != nil {
+			return err
+		}
+		if len(reminders) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(reminders))
+		for _, r := range reminders {
+			ids = append(ids, r.ID)
+		}
+		return tx.Model(&models.ClassReminder{}).Where("id IN ?", ids).
+			Update("send_at", time.Now().Add(reminderClaimLease)).Error
+	})
+
+	return reminders, err
+}
+
+// send delivers one reminder and reports whether it left the queue. A failed
+// attempt is retried later with exponential backoff.
+func (rs *ReminderScheduler) send(ctx context.Context, reminder *models.ClassReminder) bool {
+	var reservation models.Reservation
+	if err := rs.DB.First(&reservation, "id = ?", reminder.ReservationID).Error; err != nil ||
+		!remindable(reservation.Status) || reservation.ScheduleID != reminder.ScheduleID {
+		if err := rs.DB.Model(reminder).Update("status", models.ReminderCancelled).Error; err != nil {
+			log.Printf("Cancelling reminder %s failed: %v", reminder.ID, err)
+			return false
+		}
+		return true
+	}
+
+	data, err := rs.Notifier.ReservationData(reservation.ID)
+	if err == nil {
+		data["Hours"] = reminder.OffsetMinutes / 60
+		data["Minutes"] = reminder.OffsetMinutes
+		// The delivery log dedups on the reminder ID, so a crash after sending
+		// but before marking it sent does not send it again
+		err = rs.Notifier.Notify(ctx, reservation.UserID, TemplateClassReminder, data, "reminder:"+reminder.ID)
+	}
+
+	settled := true
+	updates := map[string]interface{}{"attempts": reminder.Attempts + 1}
+	if err != nil {
+		updates["last_error"] = err.Error()
+		if reminder.Attempts+1 >= reminderMaxAttempts {
+			updates["status"] = models.ReminderFailed
+		} else {
+			updates["send_at"] = time.Now().Add(reminderRetryDelay << reminder.Attempts)
+			settled = false
+		}
+	} else {
+		updates["status"] = models.ReminderSent
+		updates["sent_at"] = time.Now()
+	}
+	if err := rs.DB.Model(reminder).Updates(updates).Error; err != nil {
+		// The lease runs out and the reminder is picked up again
+		log.Printf("Updating reminder %s failed: %v", reminder.ID, err)
+		return false
+	}
+	return settled
+}
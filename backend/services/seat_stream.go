@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+const seatHistorySize = 1000
+
+// SeatStreamEvents are the event types that can change seat counts
+var SeatStreamEvents = []string{
+	events.ReservationCreated,
+	events.ReservationPaid,
+	events.ReservationCancelled,
+	events.ReservationRefunded, // a refunded booking frees its seats
+	events.ReservationRescheduled,
+	events.ScheduleChanged,
+}
+
+// SeatUpdate is the seat count of one schedule at a point in time
+type SeatUpdate struct {
+	ScheduleID  string           `json:"schedule_id"`
+	CourtID     string           `json:"court_id"`
+	CourtName   string           `json:"court_name"`
+	Date        string           `json:"date"`
+	StartTime   models.TimeOfDay `json:"start_time"`
+	EndTime     models.TimeOfDay `json:"end_time"`
+	StartsAt    time.Time        `json:"starts_at"`
+	EndsAt      time.Time        `json:"ends_at"`
+	Capacity    int              `json:"capacity"`
+	SeatsBooked int              `json:"seats_booked"`
+	SeatsLeft   int              `json:"seats_left"`
+	IsAvailable bool             `json:"is_available"`
+	Removed     bool             `json:"removed,omitempty"` // the schedule was deleted; only the IDs and date are set
+}
+
+// SeatStream broadcasts seat count changes of public schedules. It is fed by
+// this process's outbox dispatcher and keeps its history in memory, so it
+// assumes a single API instance: with several, each stream would only see the
+// events its own instance happened to dispatch.
+type SeatStream struct {
+	DB *gorm.DB
+	*Broadcaster[SeatUpdate]
+}
+
+func NewSeatStream(db *gorm.DB) *SeatStream {
+	return &SeatStream{DB: db, Broadcaster: NewBroadcaster[SeatUpdate](seatHistorySize)}
+}
+
+// SeatFilter matches updates for a date and/or court; empty means any
+func SeatFilter(date, courtID string) func(SeatUpdate) bool {
+	return func(u SeatUpdate) bool {
+		return (date == "" || date == u.Date) && (courtID == "" || courtID == u.CourtID)
+	}
+}
+
+// HandleEvent recomputes and broadcasts the schedules touched by an event
+func (s *SeatStream) HandleEvent(ctx context.Context, e events.Event) error {
+	var scheduleIDs []string
+
+	switch e.Type {
+	case events.ScheduleChanged:
+		var payload events.ScheduleChangedPayload
+		if err := e.Decode(&payload); err != nil {
+			return err
+		}
+		if payload.Action == "deleted" {
+			for _, id := range payload.ScheduleIDs {
+				s.Publish(SeatUpdate{ScheduleID: id, CourtID: payload.CourtID, Date: payload.Dates[id], Removed: true})
+			}
+			return nil
+		}
+		scheduleIDs = payload.ScheduleIDs
+	case events.ReservationRescheduled:
+		var payload events.RescheduledPayload
+		if err := e.Decode(&payload); err != nil {
+			return err
+		}
+		scheduleIDs = []string{payload.FromScheduleID, payload.ScheduleID}
+	default:
+		var payload events.ReservationPayload
+		if err := e.Decode(&payload); err != nil {
+			return err
+		}
+		scheduleIDs = []string{payload.ScheduleID}
+	}
+
+	if len(scheduleIDs) == 0 {
+		return nil
+	}
+
+	updates, err := s.load(s.DB.Where("schedules.id IN ?", scheduleIDs))
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		s.Publish(u)
+	}
+	return nil
+}
+
+// Snapshot returns the current seat counts for a date and/or court
+func (s *SeatStream) Snapshot(date, courtID string) ([]SeatUpdate, error) {
+	query := s.DB
+	if date != "" {
+		query = query.Where("schedules.date = ?", date)
+	}
+	if courtID != "" {
+		query = query.Where("schedules.court_id = ?", courtID)
+	}
+	return s.load(query)
+}
+
+func (s *SeatStream) load(query *gorm.DB) ([]SeatUpdate, error) {
+	var schedules []models.Schedule
+	if err := query.Preload("Court").Where("schedules.is_private = ?", false).
+		Order("schedules.date ASC, schedules.start_time ASC").
+		Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(schedules))
+	for i, sch := range schedules {
+		ids[i] = sch.ID
+	}
+
+	var rows []struct {
+		ScheduleID string
+		Seats      int
+	}
+	if err := s.DB.Model(&models.Reservation{}).
+		Select("schedule_id, COALESCE(SUM(seats), 0) AS seats").
+		Where("schedule_id IN ? AND status IN ?", ids, domain.ActiveReservationStatuses).
+		Group("schedule_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	booked := map[string]int{}
+	for _, r := range rows {
+		booked[r.ScheduleID] = r.Seats
+	}
+
+	updates := make([]SeatUpdate, 0, len(schedules))
+	for _, sch := range schedules {
+		left := sch.SeatCapacity(sch.Court) - booked[sch.ID]
+		if left < 0 {
+			left = 0
+		}
+		updates = append(updates, SeatUpdate{
+			ScheduleID:  sch.ID,
+			CourtID:     sch.CourtID,
+			CourtName:   sch.Court.Name,
+			Date:        sch.Date.Format("2006-01-02"),
+			StartTime:   sch.StartTime,
+			EndTime:     sch.EndTime,
+			StartsAt:    sch.StartsAt(),
+			EndsAt:      sch.EndsAt(),
+			Capacity:    sch.SeatCapacity(sch.Court),
+			SeatsBooked: booked[sch.ID],
+			SeatsLeft:   left,
+			IsAvailable: sch.IsAvailable,
+		})
+	}
+	return updates, nil
+}
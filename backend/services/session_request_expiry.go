@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Giriathallah/diro-pilates-backend/domain"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+const (
+	sessionRequestExpiryInterval = 5 * time.Minute
+	defaultPrivatePaymentHours   = 24
+	// privatePaymentMargin lets a late notification settle the payment
+	// before an approval is expired
+	privatePaymentMargin = 30 * time.Minute
+)
+
+// PrivateSessionPaymentWindow is how long a customer has to pay for an
+// approved private session (PRIVATE_SESSION_PAYMENT_HOURS)
+func PrivateSessionPaymentWindow() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("PRIVATE_SESSION_PAYMENT_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultPrivatePaymentHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// StartSessionRequestExpiry periodically marks unanswered private session
+// requests as expired, and expires approvals that were never paid, until ctx
+// is cancelled
+func StartSessionRequestExpiry(ctx context.Context, db *gorm.DB, mt *MidtransService) {
+	go func() {
+		ticker := time.NewTicker(sessionRequestExpiryInterval)
+		defer ticker.Stop()
+
+		for {
+			ExpireSessionRequests(db)
+			ExpireUnpaidApprovals(db, mt)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ExpireSessionRequests marks pending requests past their deadline as expired
+func ExpireSessionRequests(db *gorm.DB) {
+	result := db.Model(&models.SessionRequest{}).
+		Where("status = ? AND expires_at < ?", models.SessionRequestPending, time.Now()).
+		Update("status", models.SessionRequestExpired)
+	if result.Error != nil {
+		log.Printf("Session request expiry failed: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Expired %d session requests", result.RowsAffected)
+	}
+}
+
+// ExpireUnpaidApprovals settles the payments of approved requests whose
+// payment window has passed. An unpaid one fails, which cancels the
+// reservation and releases the private schedule, and the request is marked
+// expired. This also covers approvals whose Snap token was never created
+// because the process died after committing the approval.
+func ExpireUnpaidApprovals(db *gorm.DB, mt *MidtransService) {
+	deadline := time.Now().Add(-PrivateSessionPaymentWindow() - privatePaymentMargin)
+
+	var payments []models.Payment
+	if err := db.Joins("JOIN session_requests ON session_requests.reservation_id = payments.reservation_id").
+		Where("session_requests.status = ? AND session_requests.reviewed_at < ? AND payments.kind = ? AND payments.status = ?",
+			models.SessionRequestApproved, deadline, models.PaymentKindBooking, domain.PaymentPending).
+		Find(&payments).Error; err != nil {
+		log.Printf("Private session payment expiry failed: %v", err)
+		return
+	}
+
+	for i := range payments {
+		settleStalePayment(db, mt, &payments[i], func(tx *gorm.DB, status string) error {
+			if status != domain.PaymentFailed {
+				return nil
+			}
+			return tx.Model(&models.SessionRequest{}).
+				Where("reservation_id = ? AND status = ?", payments[i].ReservationID, models.SessionRequestApproved).
+				Update("status", models.SessionRequestExpired).Error
+		})
+	}
+}
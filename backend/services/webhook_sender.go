@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Giriathallah/diro-pilates-backend/events"
+	"github.com/Giriathallah/diro-pilates-backend/models"
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	// webhookClaimLease keeps claimed deliveries from other instances while
+	// a batch is sent; it must outlast webhookBatchSize client timeouts
+	webhookClaimLease = 5 * time.Minute
+)
+
+// OutboundWebhookEvents are the event types subscriptions can choose from
+var OutboundWebhookEvents = []string{
+	events.ReservationCreated,
+	events.ReservationPaid,
+	events.ReservationCancelled,
+	events.ScheduleChanged,
+	events.PaymentRefunded,
+	events.ReservationRescheduled,
+}
+
+// WebhookSender fans domain events out to webhook subscriptions and delivers
+// them with HMAC-SHA256 signatures, retrying failures with exponential backoff
+type WebhookSender struct {
+	DB     *gorm.DB
+	Client *http.Client
+	wake   chan struct{}
+}
+
+func NewWebhookSender(db *gorm.DB) *WebhookSender {
+	return &WebhookSender{
+		DB:     db,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// webhookBody is the JSON document POSTed to subscribers
+type webhookBody struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enqueue is an events.Handler creating one delivery per matching subscription.
+// The unique (subscription, event) index makes repeated delivery of the same event harmless.
+func (w *WebhookSender) Enqueue(ctx context.Context, e events.Event) error {
+	var subs []models.WebhookSubscription
+	if err := w.DB.WithContext(ctx).Where("is_active = ?", true).Find(&subs).Error; err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookBody{ID: e.ID, Type: e.Type, CreatedAt: e.CreatedAt, Data: e.Payload})
+	if err != nil {
+		return err
+	}
+
+	queued := false
+	for _, sub := range subs {
+		if !sub.Wants(e.Type) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        body,
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  time.Now(),
+		}
+		if err := w.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery).Error; err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		w.Wake()
+	}
+	return nil
+}
+
+// Wake asks the sender to attempt due deliveries now
+func (w *WebhookSender) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery loop until ctx is cancelled
+func (w *WebhookSender) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+
+		for {
+			w.deliverDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-w.wake:
+			}
+		}
+	}()
+}
+
+func (w *WebhookSender) deliverDue(ctx context.Context) {
+	due, err := w.claim(ctx)
+	if err != nil {
+		log.Printf("Webhook sender: failed to claim deliveries: %v", err)
+		return
+	}
+
+	for i := range due {
+		w.attempt(ctx, &due[i])
+	}
+}
+
+// claim picks due deliveries and leases them for webhookClaimLease in a short
+// transaction, the same way the outbox dispatcher claims events, so several
+// instances never send the same delivery and no lock is held during HTTP calls.
+// A delivery whose instance dies mid-send is retried once the lease runs out.
+func (w *WebhookSender) claim(ctx context.Context) ([]models.WebhookDelivery, error) {
+	var claimed []models.WebhookDelivery
+	var ids []string
+
+	err := w.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(webhookBatchSize).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		for _, d := range claimed {
+			ids = append(ids, d.ID)
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(webhookClaimLease)).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// Subscriptions are loaded outside the locking transaction
+	var due []models.WebhookDelivery
+	if err := w.DB.WithContext(ctx).Preload("Subscription").
+		Where("id IN ?", ids).
+		Order("next_attempt_at ASC").
+		Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	return due, nil
+}
+
+func (w *WebhookSender) attempt(ctx context.Context, d *models.WebhookDelivery) {
+	now := time.Now()
+	d.Attempts++
+	record := models.WebhookDeliveryAttempt{
+		DeliveryID:  d.ID,
+		Attempt:     d.Attempts,
+		AttemptedAt: now,
+	}
+
+	if d.Subscription == nil || !d.Subscription.IsActive {
+		d.Status = models.DeliveryFailed
+		d.LastError = "subscription inactive"
+		record.Error = d.LastError
+		w.save(d, &record)
+		return
+	}
+
+	status, body, err := w.send(ctx, d.Subscription, d)
+	d.ResponseStatus = status
+	d.ResponseBody = body
+	record.ResponseStatus = status
+	record.ResponseBody = body
+	record.DurationMs = time.Since(now).Milliseconds()
+
+	if err == nil && status >= 200 && status < 300 {
+		d.Status = models.DeliverySucceeded
+		d.DeliveredAt = &now
+		d.LastError = ""
+	} else {
+		if err != nil {
+			d.LastError = err.Error()
+		} else {
+			d.LastError = fmt.Sprintf("unexpected status %d", status)
+		}
+		record.Error = d.LastError
+
+		// Redeliver starts a new round, so only this round's attempts count
+		round := d.Attempts - d.RetryBase
+		if round >= webhookMaxAttempts {
+			d.Status = models.DeliveryFailed
+		} else {
+			d.NextAttemptAt = now.Add(webhookBackoff(round))
+		}
+	}
+
+	w.save(d, &record)
+}
+
+func (w *WebhookSender) send(ctx context.Context, sub *models.WebhookSubscription, d *models.WebhookDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pilates-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", d.ID)
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhookPayload(sub.Secret, timestamp, d.Payload))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	// Keep only the start of the response in the delivery log
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return resp.StatusCode, string(respBody), nil
+}
+
+// save stores the delivery's new state together with the attempt that led to it
+func (w *WebhookSender) save(d *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) {
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(d).Updates(map[string]interface{}{
+			"status":          d.Status,
+			"attempts":        d.Attempts,
+			"next_attempt_at": d.NextAttemptAt,
+			"response_status": d.ResponseStatus,
+			"response_body":   d.ResponseBody,
+			"last_error":      d.LastError,
+			"delivered_at":    d.DeliveredAt,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Webhook sender: failed to update delivery %s: %v", d.ID, err)
+	}
+}
+
+// SignWebhookPayload returns hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Receivers recompute it from the X-Webhook-Timestamp header and the raw body.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempt int) time.Duration {
+	d := webhookBaseBackoff * time.Duration(1<<uint(attempt-1))
+	if d > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return d
+}
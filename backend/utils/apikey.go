@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const apiKeyPrefix = "pk_"
+
+// GenerateAPIKey returns a new random key together with its display prefix
+func GenerateAPIKey() (key string, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(b)
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+// HashAPIKey is the value stored and looked up in api_keys.key_hash
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
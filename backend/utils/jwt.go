@@ -7,17 +7,46 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// GenerateToken issues a session token. iat records when the user logged in,
+// which accounts without a password use to confirm sensitive changes.
 func GenerateToken(userID string, role string) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(), // 3 days
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Hour * 72).Unix(), // 3 days
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 }
 
+// GenerateImpersonationToken issues a short-lived token for userID that is
+// flagged with the admin who started the impersonation session.
+func GenerateImpersonationToken(userID string, role string, impersonatorID string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":         userID,
+		"role":            role,
+		"impersonator_id": impersonatorID,
+		"exp":             time.Now().Add(ttl).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// GenerateSignedClaims signs arbitrary short-lived claims, e.g. OIDC login
+// state kept in a cookie. The typ claim keeps them from being accepted as a
+// session token; read them back with ParseSignedClaims.
+func GenerateSignedClaims(typ string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	claims["typ"] = typ
+	claims["exp"] = time.Now().Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
 func ParseToken(tokenString string) (*jwt.Token, error) {
 	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -26,3 +55,16 @@ func ParseToken(tokenString string) (*jwt.Token, error) {
 		return []byte(os.Getenv("JWT_SECRET")), nil
 	})
 }
+
+// ParseSignedClaims verifies a token from GenerateSignedClaims and checks its typ
+func ParseSignedClaims(tokenString, typ string) (jwt.MapClaims, error) {
+	token, err := ParseToken(tokenString)
+	if err != nil || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != typ {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
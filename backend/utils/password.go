@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// unusablePasswordPrefix can never be produced by bcrypt, so hashes starting
+// with it never match any password.
+const unusablePasswordPrefix = "!"
+
+// UnusablePasswordHash is stored for accounts that must not log in with a
+// password (social-only sign up, deleted accounts)
+func UnusablePasswordHash() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return unusablePasswordPrefix + hex.EncodeToString(b), nil
+}
+
+func IsUnusablePassword(hash string) bool {
+	return strings.HasPrefix(hash, unusablePasswordPrefix)
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// NormalizePhone converts Indonesian and international numbers to E.164,
+// e.g. "0812-3456-789" and "62812345678" become "+62812345678"
+func NormalizePhone(phone string) (string, error) {
+	cleaned := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(phone)
+
+	switch {
+	case strings.HasPrefix(cleaned, "+"):
+		cleaned = cleaned[1:]
+	case strings.HasPrefix(cleaned, "0"):
+		cleaned = "62" + cleaned[1:]
+	}
+
+	if len(cleaned) < 9 || len(cleaned) > 15 {
+		return "", errors.New("invalid phone number length")
+	}
+	for _, r := range cleaned {
+		if r < '0' || r > '9' {
+			return "", errors.New("phone number may only contain digits")
+		}
+	}
+
+	return "+" + cleaned, nil
+}
+
+// GenerateOTP returns a random numeric code with the given number of digits
+func GenerateOTP(digits int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
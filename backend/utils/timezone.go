@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultStudioTimezone = "Asia/Jakarta"
+
+var (
+	studioOnce     sync.Once
+	studioTimezone string
+	studioLocation *time.Location
+)
+
+func loadStudioTimezone() {
+	studioTimezone = os.Getenv("STUDIO_TIMEZONE")
+	if studioTimezone == "" {
+		studioTimezone = defaultStudioTimezone
+	}
+
+	loc, err := time.LoadLocation(studioTimezone)
+	if err != nil {
+		log.Printf("Invalid STUDIO_TIMEZONE %q, using %s: %v", studioTimezone, defaultStudioTimezone, err)
+		studioTimezone = defaultStudioTimezone
+		loc, err = time.LoadLocation(defaultStudioTimezone)
+		if err != nil {
+			// No tz database at all: WIB has been a fixed UTC+7 since 1964
+			loc = time.FixedZone("WIB", 7*60*60)
+		}
+	}
+	studioLocation = loc
+}
+
+// StudioTimezone is the IANA name of the studio timezone (STUDIO_TIMEZONE,
+// default Asia/Jakarta). Schedule dates and times are wall-clock values in it.
+func StudioTimezone() string {
+	studioOnce.Do(loadStudioTimezone)
+	return studioTimezone
+}
+
+// StudioLocation is the studio timezone
+func StudioLocation() *time.Location {
+	studioOnce.Do(loadStudioTimezone)
+	return studioLocation
+}
+
+// StudioNow is the current time in the studio timezone
+func StudioNow() time.Time {
+	return time.Now().In(StudioLocation())
+}
+
+// StudioToday is midnight at the start of the current studio day
+func StudioToday() time.Time {
+	now := StudioNow()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// StudioDate is the studio calendar date of t as YYYY-MM-DD, the form to
+// compare against DATE columns
+func StudioDate(t time.Time) string {
+	return t.In(StudioLocation()).Format("2006-01-02")
+}